@@ -0,0 +1,105 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements an optional cache of tree matches
+// for frequently requested paths.
+
+package muxpatterns
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MatchCache returns an Option that makes the ServeMux remember the results
+// of the most recent size distinct (method, host, path) lookups, so that
+// repeated requests for the same URL skip the tree traversal.
+// It trades memory for latency, and is only worthwhile when a small set of
+// concrete URLs dominates traffic.
+//
+// The cache is cleared whenever a pattern is registered.
+// If size is not positive, there is no cache.
+func MatchCache(size int) Option {
+	return func(mux *ServeMux) {
+		if size <= 0 {
+			mux.cache = nil
+			return
+		}
+		mux.cache = newMatchCache(size)
+	}
+}
+
+type cacheKey struct {
+	method, host, path string
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	n       *node
+	matches []string
+}
+
+// A matchCache is a bounded LRU cache from request keys to
+// the results of node.match.
+// Only successful matches are cached, so that requests for
+// nonexistent paths cannot evict useful entries.
+type matchCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[cacheKey]*list.Element // values are *cacheEntry
+	lru     list.List                  // front is most recently used
+}
+
+func newMatchCache(size int) *matchCache {
+	return &matchCache{size: size, entries: map[cacheKey]*list.Element{}}
+}
+
+// get returns the cached match for key.
+// The returned matches are a copy, so the caller may modify them.
+func (c *matchCache) get(key cacheKey) (*node, []string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, nil, false
+	}
+	c.lru.MoveToFront(e)
+	ce := e.Value.(*cacheEntry)
+	return ce.n, copyMatches(ce.matches), true
+}
+
+// put adds a match to the cache, evicting the least recently used
+// entry if the cache is full.
+func (c *matchCache) put(key cacheKey, n *node, matches []string) {
+	if n == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		return
+	}
+	if c.lru.Len() >= c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, n: n, matches: copyMatches(matches)})
+}
+
+// clear removes all entries from the cache.
+func (c *matchCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[cacheKey]*list.Element{}
+	c.lru.Init()
+}
+
+func copyMatches(ms []string) []string {
+	if ms == nil {
+		return nil
+	}
+	return append([]string(nil), ms...)
+}
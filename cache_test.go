@@ -0,0 +1,63 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package muxpatterns
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchCacheEviction(t *testing.T) {
+	c := newMatchCache(2)
+	n := &node{}
+	k := func(p string) cacheKey { return cacheKey{"GET", "", p} }
+	c.put(k("/a"), n, nil)
+	c.put(k("/b"), n, nil)
+	c.get(k("/a")) // make /b the least recently used
+	c.put(k("/c"), n, nil)
+	c.put(k("/d"), nil, nil) // misses are not cached
+	for _, test := range []struct {
+		path string
+		want bool
+	}{
+		{"/a", true},
+		{"/b", false},
+		{"/c", true},
+		{"/d", false},
+	} {
+		if _, _, got := c.get(k(test.path)); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.path, got, test.want)
+		}
+	}
+}
+
+func TestMatchCacheServeMux(t *testing.T) {
+	var got string
+	mux := NewServeMux(MatchCache(10))
+	mux.HandleFunc("/a/{x}", func(w http.ResponseWriter, r *http.Request) {
+		got = "x=" + PathValue(r, "x")
+		SetPathValue(r, "x", "changed")
+	})
+	serve := func(path string) string {
+		got = ""
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return got
+	}
+	for i := 0; i < 2; i++ {
+		// The second time, SetPathValue from the first must not be visible.
+		if g, w := serve("/a/b"), "x=b"; g != w {
+			t.Errorf("#%d: got %q, want %q", i, g, w)
+		}
+	}
+	// Registering a more specific pattern invalidates the cache.
+	mux.HandleFunc("/a/b", func(w http.ResponseWriter, r *http.Request) {
+		got = "literal"
+	})
+	if g, w := serve("/a/b"), "literal"; g != w {
+		t.Errorf("after register: got %q, want %q", g, w)
+	}
+}
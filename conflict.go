@@ -0,0 +1,126 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Reporting conflicts between patterns.
+
+package muxpatterns
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// A Conflict is a pair of patterns that conflict: there is a request that
+// both match, but neither has higher precedence than the other.
+type Conflict struct {
+	Pattern1, Pattern2 *Pattern
+}
+
+// Relationship returns "equivalent" if the patterns match the same
+// requests, or "overlaps" if each matches some requests that the other
+// doesn't.
+func (c Conflict) Relationship() string {
+	return string(c.Pattern1.comparePathsAndMethods(c.Pattern2))
+}
+
+// ExamplePath returns a path that both patterns match.
+func (c Conflict) ExamplePath() string {
+	return commonPath(c.Pattern1, c.Pattern2)
+}
+
+// Description returns a human-readable explanation of the conflict.
+func (c Conflict) Description() string {
+	return describeRel(c.Pattern1, c.Pattern2)
+}
+
+// A ConflictReport lists conflicts between patterns.
+// Its JSON form is meant for programs like CI checks.
+type ConflictReport struct {
+	Conflicts []Conflict
+}
+
+type jsonConflict struct {
+	Pattern1     string `json:"pattern1"`
+	Location1    string `json:"location1,omitempty"`
+	Pattern2     string `json:"pattern2"`
+	Location2    string `json:"location2,omitempty"`
+	Relationship string `json:"relationship"`
+	ExamplePath  string `json:"examplePath"`
+	Description  string `json:"description"`
+}
+
+// MarshalJSON encodes the report as a JSON object with a "conflicts" array.
+// Each element holds the two patterns and, if known, the source locations
+// where they were registered, along with the relationship between them,
+// an example path that both match, and a description.
+func (r ConflictReport) MarshalJSON() ([]byte, error) {
+	jcs := []jsonConflict{}
+	for _, c := range r.Conflicts {
+		jcs = append(jcs, jsonConflict{
+			Pattern1:     c.Pattern1.String(),
+			Location1:    c.Pattern1.loc,
+			Pattern2:     c.Pattern2.String(),
+			Location2:    c.Pattern2.loc,
+			Relationship: c.Relationship(),
+			ExamplePath:  c.ExamplePath(),
+			Description:  c.Description(),
+		})
+	}
+	return json.Marshal(struct {
+		Conflicts []jsonConflict `json:"conflicts"`
+	}{jcs})
+}
+
+// A ConflictError is returned when registering a pattern that conflicts
+// with one that is already registered. Handle and HandleFunc panic with it.
+type ConflictError struct {
+	Conflict Conflict // Pattern1 is the pattern being registered
+}
+
+func (e *ConflictError) Error() string {
+	p1, p2 := e.Conflict.Pattern1, e.Conflict.Pattern2
+	return fmt.Sprintf("pattern %q (registered at %s) conflicts with pattern %q (registered at %s):\n%s",
+		p1, p1.loc, p2, p2.loc, e.Conflict.Description())
+}
+
+// Report returns a ConflictReport holding the error's conflict.
+func (e *ConflictError) Report() *ConflictReport {
+	return &ConflictReport{Conflicts: []Conflict{e.Conflict}}
+}
+
+// CheckConflicts reports every pair of conflicting patterns in the list,
+// rather than stopping at the first as registration does.
+// Conflicts are in the order of the later pattern of each pair in the list,
+// then of the earlier one; the later pattern is Pattern1.
+// It returns an error if a pattern is invalid.
+func CheckConflicts(patterns []string) (*ConflictReport, error) {
+	report := &ConflictReport{}
+	idx := newIndex()
+	pos := map[*Pattern]int{}
+	for i, s := range patterns {
+		pat, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		// The index may present the same pattern more than once, and in
+		// no particular order.
+		var conflicts []Conflict
+		seen := map[*Pattern]bool{}
+		idx.possiblyConflictingPatterns(pat, func(pat2 *Pattern) error {
+			if !seen[pat2] && pat.ConflictsWith(pat2) {
+				conflicts = append(conflicts, Conflict{pat, pat2})
+			}
+			seen[pat2] = true
+			return nil
+		})
+		sort.Slice(conflicts, func(i, j int) bool {
+			return pos[conflicts[i].Pattern2] < pos[conflicts[j].Pattern2]
+		})
+		report.Conflicts = append(report.Conflicts, conflicts...)
+		idx.addPattern(pat)
+		pos[pat] = i
+	}
+	return report, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package muxpatterns
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCheckConflicts(t *testing.T) {
+	report, err := CheckConflicts([]string{
+		"/a/{x}",
+		"/a/b",
+		"/{y}/b",
+		"/a/{z}",
+		"GET /c/",
+		"/c/{w...}",
+		"/d",
+		"GET /{v}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range report.Conflicts {
+		got = append(got, c.Pattern1.String()+" "+c.Relationship()+" "+c.Pattern2.String()+" at "+c.ExamplePath())
+	}
+	want := []string{
+		"/{y}/b overlaps /a/{x} at /a/b",
+		"/a/{z} equivalent /a/{x} at /a/x",
+		"/a/{z} overlaps /{y}/b at /a/b",
+		"GET /c/ overlaps /{y}/b at /c/b",
+		"/c/{w...} overlaps /{y}/b at /c/b",
+		"GET /{v} overlaps /d at /d",
+	}
+	if g, w := strings.Join(got, "\n"), strings.Join(want, "\n"); g != w {
+		t.Errorf("got\n%s\nwant\n%s", g, w)
+	}
+
+	if _, err := CheckConflicts([]string{"/a", "/{"}); err == nil {
+		t.Error("invalid pattern: got nil error")
+	}
+}
+
+func TestConflictReportJSON(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("/a/{x}/", http.NotFoundHandler())
+	err := mux.register("/a/{y}/{z...}", http.NotFoundHandler())
+	var cerr *ConflictError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("got %v, want a *ConflictError", err)
+	}
+	data, err := json.Marshal(cerr.Report())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Conflicts []map[string]string
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Conflicts) != 1 {
+		t.Fatalf("got %d conflicts, want 1:\n%s", len(got.Conflicts), data)
+	}
+	c := got.Conflicts[0]
+	for key, want := range map[string]string{
+		"pattern1":     "/a/{y}/{z...}",
+		"pattern2":     "/a/{x}/",
+		"relationship": "equivalent",
+		"examplePath":  "/a/x/",
+	} {
+		if c[key] != want {
+			t.Errorf("%s: got %q, want %q", key, c[key], want)
+		}
+	}
+	for _, key := range []string{"location1", "location2"} {
+		if !strings.Contains(c[key], "conflict_test.go:") {
+			t.Errorf("%s: got %q, want a location in conflict_test.go", key, c[key])
+		}
+	}
+	if c["description"] == "" {
+		t.Error("empty description")
+	}
+
+	// A report that is not addressable encodes the same way.
+	data2, err := json.Marshal(struct{ R ConflictReport }{*cerr.Report()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := string(data2), `{"R":`+string(data)+`}`; g != w {
+		t.Errorf("got\n%s\nwant\n%s", g, w)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Unregistering patterns while requests are being served.
+
+package muxpatterns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Drainable returns an Option that makes the ServeMux count the requests in
+// flight for each pattern, so that [ServeMux.UnregisterAndDrain] can wait
+// for them. Counting costs atomic operations on every request, which
+// contend on busy patterns, so it is off by default.
+func Drainable() Option {
+	return func(mux *ServeMux) {
+		mux.drainable = true
+	}
+}
+
+// UnregisterAndDrain removes pattern, which must be the string of a
+// registered pattern, from mux. No request that arrives after it is called
+// will match the pattern. It then waits until all requests already
+// dispatched to the pattern's handler, by ServeHTTP or by a handler returned
+// from Handler, have completed, or until ctx is done, in which case it
+// returns ctx.Err().
+//
+// After UnregisterAndDrain returns nil, mux holds no reference to the handler
+// and it is safe to release any resources the handler uses.
+//
+// UnregisterAndDrain returns an error without removing the pattern unless
+// mux was created with the [Drainable] option.
+func (mux *ServeMux) UnregisterAndDrain(ctx context.Context, pattern string) error {
+	if !mux.drainable {
+		return errors.New("UnregisterAndDrain requires the Drainable option")
+	}
+	pat, err := mux.parse(pattern)
+	if err != nil {
+		return err
+	}
+	mux.mu.Lock()
+	leaf := mux.tree.removePattern(pat)
+	if leaf == nil {
+		mux.mu.Unlock()
+		return fmt.Errorf("pattern %q is not registered", pattern)
+	}
+	mux.index.removePattern(leaf.pattern)
+	if mux.cache != nil {
+		mux.cache.clear()
+	}
+	leaf.markRemoved()
+	mux.mu.Unlock()
+
+	select {
+	case <-leaf.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// inFlightHandler returns a handler that calls h, counting its requests as
+// in flight for n. A request that arrives after n's pattern has been
+// unregistered is matched again.
+func (mux *ServeMux) inFlightHandler(h http.Handler, n *node) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !n.acquire() {
+			h, _ := mux.Handler(r)
+			h.ServeHTTP(w, r)
+			return
+		}
+		defer n.release()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// removedBit is set in node.inflight when the node's pattern has been
+// unregistered. The other bits count requests in flight.
+const removedBit = 1 << 62
+
+// acquire records a request in flight for n.
+// It reports false if n's pattern has been unregistered, in which case
+// the request must be matched again.
+func (n *node) acquire() bool {
+	for {
+		s := n.inflight.Load()
+		if s&removedBit != 0 {
+			return false
+		}
+		if n.inflight.CompareAndSwap(s, s+1) {
+			return true
+		}
+	}
+}
+
+// release records the end of a request in flight for n.
+func (n *node) release() {
+	if n.inflight.Add(-1) == removedBit {
+		// The pattern was unregistered and this was the last request.
+		close(n.drained)
+	}
+}
+
+// markRemoved records that n's pattern has been unregistered.
+// n.drained is closed once no requests are in flight.
+func (n *node) markRemoved() {
+	n.drained = make(chan struct{})
+	for {
+		s := n.inflight.Load()
+		if n.inflight.CompareAndSwap(s, s|removedBit) {
+			if s == 0 {
+				close(n.drained)
+			}
+			return
+		}
+	}
+}
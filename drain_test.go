@@ -0,0 +1,164 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package muxpatterns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemovePattern(t *testing.T) {
+	tree := buildTree("/a", "/a/b", "/a/{x}", "/a/b/{x...}", "/a/b/{$}", "GET h.com/c", "*.h.com/c")
+	for _, p := range []string{"/a", "/a/b/{x...}", "/a/{x}", "/a/{y}", "GET h.com/c", "h.com/c", "*.h.com/c"} {
+		tree.removePattern(mustParse(t, p))
+	}
+	want := `"":
+    "":
+        "a":
+            "b":
+                "/a/b"
+                "/":
+                    "/a/b/{$}"
+`
+	var b strings.Builder
+	tree.print(&b, 0)
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if tree.hostGlobs.len() != 0 {
+		t.Error("host glob not removed")
+	}
+	// Adding a removed multi must not panic.
+	tree.addPattern(mustParse(t, "/a/b/{x...}"), nil)
+}
+
+func TestUnregisterAndDrain(t *testing.T) {
+	mux := NewServeMux(MatchCache(10), Drainable())
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	mux.HandleFunc("/slow/{x}", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	serve := func() int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/slow/1", nil))
+		return w.Code
+	}
+
+	served := make(chan int)
+	go func() { served <- serve() }()
+	<-started
+	leaf, _ := mux.tree.match("GET", "", "/slow/1")
+
+	// Draining times out while the request is in flight.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mux.UnregisterAndDrain(ctx, "/slow/{x}"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	// The request still completes, and then the leaf is drained.
+	close(finish)
+	if g, w := <-served, http.StatusOK; g != w {
+		t.Errorf("in-flight request: got %d, want %d", g, w)
+	}
+	select {
+	case <-leaf.drained:
+	case <-time.After(time.Second):
+		t.Fatal("leaf not drained after the request finished")
+	}
+	// The pattern is already gone, so requests fall through to "/".
+	if g, w := serve(), http.StatusTeapot; g != w {
+		t.Errorf("after unregistering: got %d, want %d", g, w)
+	}
+	// The pattern can be registered again, and unregistered again.
+	done := make(chan struct{})
+	mux.HandleFunc("/slow/{y}", func(w http.ResponseWriter, r *http.Request) { <-done })
+	close(done)
+	if err := mux.UnregisterAndDrain(context.Background(), "/slow/{y}"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.UnregisterAndDrain(context.Background(), "/slow/{x}"); err == nil {
+		t.Error("unregistering twice: got nil, want error")
+	}
+}
+
+func TestUnregisterAndDrainWaits(t *testing.T) {
+	mux := NewServeMux(Drainable())
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	var finished bool
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		finished = true
+	})
+	go mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-started
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(finish)
+	}()
+	if err := mux.UnregisterAndDrain(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+	if !finished {
+		t.Error("UnregisterAndDrain returned before the request finished")
+	}
+}
+
+func TestUnregisterAndDrainHandler(t *testing.T) {
+	mux := NewServeMux(Drainable())
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+	})
+	r := httptest.NewRequest("GET", "/", nil)
+	h, _ := mux.Handler(r)
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		close(done)
+	}()
+	<-started
+
+	// A request served by a handler from Handler is in flight too.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mux.UnregisterAndDrain(ctx, "/"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(finish)
+	<-done
+
+	// The same handler, called after the pattern is gone, matches again.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if g, w := w.Code, http.StatusNotFound; g != w {
+		t.Errorf("after unregistering: got %d, want %d", g, w)
+	}
+}
+
+func TestUnregisterAndDrainNotDrainable(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("/", http.NotFoundHandler())
+	if err := mux.UnregisterAndDrain(context.Background(), "/"); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if _, pat := mux.Handler(httptest.NewRequest("GET", "/", nil)); pat != "/" {
+		t.Errorf("pattern removed: matched %q", pat)
+	}
+}
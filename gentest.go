@@ -0,0 +1,75 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Generating tests for registered routes.
+
+package muxpatterns
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+)
+
+// WriteRouteTests writes to w the source of a Go test file in package pkg
+// that checks that every pattern registered on mux is still routed.
+// The test has one subtest per pattern. Each sends a request for the
+// pattern's [Pattern.ExamplePath] to the http.Handler that the Go expression
+// handlerExpr evaluates to, and fails if the response is 404 Not Found.
+// If importPath is non-empty, the file imports it, so that handlerExpr can
+// refer to that package.
+//
+// The result is a skeleton: handlerExpr may refer to a helper that the
+// user must write, and the requests may need bodies, headers or
+// more realistic wildcard values.
+func WriteRouteTests(w io.Writer, mux *ServeMux, pkg, importPath, handlerExpr string) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `// Code generated by muxpatterns.WriteRouteTests. Edit as needed.
+
+package %s
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+`, pkg)
+	if importPath != "" {
+		fmt.Fprintf(&b, "\n\t%q\n", importPath)
+	}
+	fmt.Fprintf(&b, `)
+
+func TestRoutes(t *testing.T) {
+	var handler http.Handler = %s
+`, handlerExpr)
+	for _, p := range mux.Routes() {
+		method := p.method
+		if method == "" {
+			method = "GET"
+		}
+		host := p.host
+		if host == "" {
+			host = "example.com"
+		} else if isHostGlob(host) {
+			host = "www" + host[1:]
+		}
+		fmt.Fprintf(&b, `
+	t.Run(%q, func(t *testing.T) {
+		req := httptest.NewRequest(%q, %q, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code == http.StatusNotFound {
+			t.Errorf("%%s %%s: got 404", req.Method, req.URL)
+		}
+	})
+`, p, method, "http://"+host+p.ExamplePath())
+	}
+	b.WriteString("}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting generated tests: %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package muxpatterns
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWriteRouteTests(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("GET /items/{id}", http.NotFoundHandler())
+	mux.Handle("api.com/", http.NotFoundHandler())
+	var b strings.Builder
+	if err := WriteRouteTests(&b, mux, "app_test", "example.com/app", "app.NewHandler()"); err != nil {
+		t.Fatal(err)
+	}
+	want := `// Code generated by muxpatterns.WriteRouteTests. Edit as needed.
+
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/app"
+)
+
+func TestRoutes(t *testing.T) {
+	var handler http.Handler = app.NewHandler()
+
+	t.Run("GET /items/{id}", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://example.com/items/id", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code == http.StatusNotFound {
+			t.Errorf("%s %s: got 404", req.Method, req.URL)
+		}
+	})
+
+	t.Run("api.com/", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://api.com/", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code == http.StatusNotFound {
+			t.Errorf("%s %s: got 404", req.Method, req.URL)
+		}
+	})
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteRouteTestsLegacy(t *testing.T) {
+	mux := NewServeMux(LegacyMode())
+	mux.Handle("/a b/", http.NotFoundHandler())
+	var b strings.Builder
+	if err := WriteRouteTests(&b, mux, "app", "", "newHandler()"); err != nil {
+		t.Fatal(err)
+	}
+	// The request target must be escaped, or httptest.NewRequest panics.
+	if want := `"http://example.com/a%20b/"`; !strings.Contains(b.String(), want) {
+		t.Errorf("output does not contain %s:\n%s", want, b.String())
+	}
+}
@@ -36,6 +36,34 @@ func (idx *index) addPattern(pat *Pattern) {
 	}
 }
 
+// removePattern removes pat, which must have been added, from the index.
+func (idx *index) removePattern(pat *Pattern) {
+	if pat.lastSegment().multi {
+		idx.multis = removePattern(idx.multis, pat)
+	} else {
+		for pos, seg := range pat.segments {
+			key := indexKey{pos: pos, s: ""}
+			if !seg.wild {
+				key.s = seg.s
+			}
+			if pats := removePattern(idx.segments[key], pat); len(pats) > 0 {
+				idx.segments[key] = pats
+			} else {
+				delete(idx.segments, key)
+			}
+		}
+	}
+}
+
+func removePattern(pats []*Pattern, pat *Pattern) []*Pattern {
+	for i, p := range pats {
+		if p == pat {
+			return append(pats[:i:i], pats[i+1:]...)
+		}
+	}
+	return pats
+}
+
 // possiblyConflictingPatterns calls f on all patterns that might conflict with pat.
 func (idx *index) possiblyConflictingPatterns(pat *Pattern, f func(*Pattern) error) (err error) {
 	// Terminology:
@@ -88,8 +116,19 @@ func (idx *index) possiblyConflictingPatterns(pat *Pattern, f func(*Pattern) err
 		}
 		if !hasLit {
 			// This pattern is all wildcards.
-			// It can only conflict with a multi, or an equivalent pattern.
-			apply(idx.segments[indexKey{s: "", pos: len(pat.segments) - 1}])
+			// Without a method, it can only conflict with a multi, or an equivalent pattern.
+			// With a method, it can also overlap any pattern of the same length
+			// that has no method, like "/b" and "GET /{x}".
+			last := len(pat.segments) - 1
+			if pat.method == "" {
+				apply(idx.segments[indexKey{s: "", pos: last}])
+			} else {
+				for k, pats := range idx.segments {
+					if k.pos == last {
+						apply(pats)
+					}
+				}
+			}
 		} else {
 			apply(lmin)
 			apply(wmin)
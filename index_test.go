@@ -15,7 +15,7 @@ import (
 )
 
 func TestIndex(t *testing.T) {
-	pats := []string{"HEAD /"}
+	pats := []string{"HEAD /", "/b"}
 
 	var patterns []*Pattern
 	idx := newIndex()
@@ -35,6 +35,7 @@ func TestIndex(t *testing.T) {
 	}
 
 	compare(mustParse(t, "GET /foo"))
+	compare(mustParse(t, "GET /{x}"))
 }
 
 // This test works by comparing possiblyConflictingPatterns with
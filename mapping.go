@@ -38,6 +38,28 @@ func (h *mapping[K, V]) add(k K, v V) {
 	}
 }
 
+// remove removes the pair with the given key, if there is one.
+func (h *mapping[K, V]) remove(k K) {
+	if h.m != nil {
+		delete(h.m, k)
+		return
+	}
+	for i, e := range h.s {
+		if e.key == k {
+			h.s = append(h.s[:i], h.s[i+1:]...)
+			return
+		}
+	}
+}
+
+// len returns the number of pairs in the mapping.
+func (h *mapping[K, V]) len() int {
+	if h == nil {
+		return 0
+	}
+	return len(h.s) + len(h.m)
+}
+
 // find returns the value corresponding to the given key.
 // The second return value is false if there is no value
 // with that key.
@@ -0,0 +1,120 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Help for moving patterns from net/http.ServeMux.
+
+package muxpatterns
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A MigrationIssue describes a pattern written for [net/http.ServeMux]
+// whose meaning changes under the rules of this package.
+type MigrationIssue struct {
+	Pattern string // the original pattern
+	Problem string // what changes
+	// A pattern with the original meaning under the new rules,
+	// or empty if there is none.
+	Rewrite string
+}
+
+func (mi MigrationIssue) String() string {
+	if mi.Rewrite == "" {
+		return fmt.Sprintf("%q: %s", mi.Pattern, mi.Problem)
+	}
+	return fmt.Sprintf("%q: %s; use %q", mi.Pattern, mi.Problem, mi.Rewrite)
+}
+
+// CheckMigration reports the patterns, written for net/http.ServeMux,
+// that would change meaning if registered on a ServeMux from this package
+// without [LegacyMode], and suggests rewrites where possible.
+//
+// A pattern can change meaning because:
+//   - text before a space is now a method;
+//   - a host beginning with "*." now matches any subdomain;
+//   - segments containing braces are now wildcards, or are invalid;
+//   - literal characters that are escaped in URLs, like spaces, are now
+//     compared with the escaped request path;
+//   - it now conflicts with another pattern in the list.
+func CheckMigration(patterns []string) []MigrationIssue {
+	var issues []MigrationIssue
+	var parsed []*Pattern
+	for _, s := range patterns {
+		pat, err := Parse(s)
+		if err == nil {
+			parsed = append(parsed, pat)
+		}
+		if mi, ok := checkLegacyPattern(s, pat, err); ok {
+			issues = append(issues, mi)
+		}
+	}
+	for i, p1 := range parsed {
+		for _, p2 := range parsed[:i] {
+			if p1.ConflictsWith(p2) {
+				issues = append(issues, MigrationIssue{
+					Pattern: p1.String(),
+					Problem: fmt.Sprintf("conflicts with %q", p2),
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// checkLegacyPattern checks a single pattern, given the result
+// of parsing it with Parse.
+func checkLegacyPattern(s string, pat *Pattern, parseErr error) (MigrationIssue, bool) {
+	mi := MigrationIssue{Pattern: s}
+	host, path, found := strings.Cut(s, "/")
+	if !found {
+		mi.Problem = "host without a path never matched, and is now invalid"
+		return mi, true
+	}
+	// This ServeMux matches literals against the escaped path,
+	// while net/http.ServeMux matches against the unescaped one.
+	// Escape the segments as LiteralPattern does, so that the rewrite
+	// differs only if the original no longer matches.
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		// net/http.ServeMux never matched dot segments, so there is no
+		// meaning to preserve.
+		if seg != "." && seg != ".." {
+			segs[i] = escapeSegment(seg)
+		}
+	}
+	rewrite := host + "/" + strings.Join(segs, "/")
+	if _, err := Parse(rewrite); err == nil && rewrite != s {
+		mi.Rewrite = rewrite
+	}
+	switch {
+	case parseErr != nil:
+		mi.Problem = fmt.Sprintf("now invalid: %v", parseErr)
+	case pat.method != "":
+		mi.Problem = fmt.Sprintf("%q is now a method", pat.method)
+		if mi.Rewrite == "" {
+			mi.Problem += fmt.Sprintf("; previously the pattern was for host %q, which no request has", host)
+		}
+	case isHostGlob(pat.host):
+		mi.Problem = fmt.Sprintf("host %q is now a glob matching any subdomain", pat.host)
+	case hasWildcard(pat):
+		mi.Problem = "segments in braces are now wildcards"
+	case mi.Rewrite != "":
+		mi.Problem = "literal is now matched against the escaped path"
+	default:
+		return mi, false
+	}
+	return mi, true
+}
+
+func hasWildcard(p *Pattern) bool {
+	for _, seg := range p.segments {
+		// Named wildcards, or "{$}".
+		if (seg.wild && seg.s != "") || (!seg.wild && seg.s == "/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package muxpatterns
+
+import (
+	"testing"
+)
+
+func TestCheckMigration(t *testing.T) {
+	got := CheckMigration([]string{
+		"/",
+		"example.com/static/",
+		"/users/{id}",
+		"/users/{name}",
+		"/a b",
+		"/a{",
+		"/café",
+		"/docs/a(b)",
+		"/café(1)",
+		"GET /x",
+		"example.com",
+	})
+	want := []MigrationIssue{
+		{"/users/{id}", "segments in braces are now wildcards", "/users/%7Bid%7D"},
+		{"/users/{name}", "segments in braces are now wildcards", "/users/%7Bname%7D"},
+		{"/a b", `now invalid: bad method "/a"`, "/a%20b"},
+		{"/a{", "now invalid: bad wildcard segment (must start with '{')", "/a%7B"},
+		{"/café", "literal is now matched against the escaped path", "/caf%C3%A9"},
+		{"/café(1)", "literal is now matched against the escaped path", "/caf%C3%A9(1)"},
+		{"GET /x", `"GET" is now a method; previously the pattern was for host "GET ", which no request has`, ""},
+		{"example.com", "host without a path never matched, and is now invalid", ""},
+		{"/users/{name}", `conflicts with "/users/{id}"`, ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d issues, want %d:\n%v", len(got), len(want), got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("#%d:\ngot  %s\nwant %s", i, got[i], want[i])
+		}
+	}
+}
@@ -13,6 +13,7 @@ package muxpatterns
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 	"unicode"
@@ -29,7 +30,8 @@ type Pattern struct {
 	// This makes most algorithms simpler.
 	segments []segment
 	loc      string // source location of registering call, for helpful messages
-
+	route    route  // options from registration
+	legacy   bool   // from parseLegacy: literal segments are unescaped
 }
 
 // A segment is a pattern piece that matches one or more path segments, or
@@ -47,6 +49,64 @@ func (p *Pattern) String() string { return p.str }
 
 func (p *Pattern) Method() string { return p.method }
 
+// Host returns the pattern's host, or the empty string if it has none.
+func (p *Pattern) Host() string { return p.host }
+
+// ExamplePath returns an escaped path that p matches.
+// Each single wildcard is replaced by its name, and a multi wildcard
+// by nothing.
+func (p *Pattern) ExamplePath() string {
+	path := matchingPath(p)
+	if p.legacy {
+		// Legacy patterns match unescaped paths.
+		return (&url.URL{Path: path}).EscapedPath()
+	}
+	return path
+}
+
+// WithPrefix returns the pattern that results from mounting p under the
+// path prefix, which may contain wildcards. For example, the pattern
+// "GET /{id}" with prefix "/users" becomes "GET /users/{id}".
+// A trailing slash on prefix is ignored.
+// It is an error if prefix does not begin with a slash, or if the
+// result is not a valid pattern. The result of a pattern from a ServeMux in
+// [LegacyMode] follows the legacy syntax.
+func (p *Pattern) WithPrefix(prefix string) (*Pattern, error) {
+	if prefix == "" || prefix[0] != '/' {
+		return nil, fmt.Errorf("prefix %q does not begin with '/'", prefix)
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	var b strings.Builder
+	if p.method != "" {
+		b.WriteString(p.method)
+		b.WriteByte(' ')
+	}
+	b.WriteString(p.host)
+	b.WriteString(prefix)
+	b.WriteString(p.path())
+	parse := Parse
+	if p.legacy {
+		parse = parseLegacy
+	}
+	q, err := parse(b.String())
+	if err != nil {
+		return nil, err
+	}
+	q.loc = p.loc
+	q.route = p.route
+	q.route.scopes = append([]string(nil), p.route.scopes...)
+	return q, nil
+}
+
+// path returns the path part of the pattern's original string.
+func (p *Pattern) path() string {
+	s := p.str
+	if p.method != "" {
+		s = s[len(p.method)+1:]
+	}
+	return s[len(p.host):]
+}
+
 func (p *Pattern) debugString() string {
 	var b strings.Builder
 	if p.method != "" {
@@ -86,7 +146,8 @@ func (p *Pattern) lastSegment() segment {
 //
 // where:
 //   - METHOD is the uppercase name of an HTTP method
-//   - HOST is a hostname
+//   - HOST is a hostname, or a glob like "*.example.com" that matches
+//     every subdomain of example.com
 //   - PATH consists of slash-separated segments, where each segment is either
 //     a literal or a wildcard of the form "{name}", "{name...}", or "{$}".
 //
@@ -119,13 +180,10 @@ func Parse(s string) (*Pattern, error) {
 	if strings.IndexByte(p.host, '{') >= 0 {
 		return nil, errors.New("host contains '{' (missing initial '/'?")
 	}
-	// At this point, rest is the path.
-
-	// An unclean path with a method that is not CONNECT can never match,
-	// because paths are cleaned before matching.
-	if method != "" && method != "CONNECT" && rest != cleanPath(rest) {
-		return nil, errors.New("non-CONNECT pattern with unclean path can never match")
+	if strings.IndexByte(p.host, '*') >= 0 && (strings.Count(p.host, "*") != 1 || !isHostGlob(p.host)) {
+		return nil, errors.New(`bad host glob (must be "*." followed by a domain)`)
 	}
+	// At this point, rest is the path.
 
 	seenNames := map[string]bool{}
 	for len(rest) > 0 {
@@ -185,6 +243,122 @@ func Parse(s string) (*Pattern, error) {
 	return p, nil
 }
 
+// parseLegacy parses a pattern with the syntax accepted by
+// net/http.ServeMux before the enhancements: an optional host followed by a
+// literal path. A pattern ending in a slash matches every path with that
+// prefix. A space is part of the path rather than a method separator, and
+// braces are rejected so that they cannot be mistaken for wildcards.
+func parseLegacy(s string) (*Pattern, error) {
+	if len(s) == 0 {
+		return nil, errors.New("empty pattern")
+	}
+	if method, _, found := strings.Cut(s, " "); found && isValidHTTPToken(method) {
+		return nil, fmt.Errorf("method %q not allowed in legacy mode", method)
+	}
+	if strings.IndexByte(s, '{') >= 0 {
+		return nil, errors.New("wildcards not allowed in legacy mode")
+	}
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return nil, errors.New("host/path missing /")
+	}
+	p := &Pattern{str: s, host: s[:i], legacy: true}
+	if isHostGlob(p.host) {
+		return nil, errors.New("host globs not allowed in legacy mode")
+	}
+	rest := s[i:]
+	for len(rest) > 0 {
+		// Invariant: rest[0] == '/'.
+		rest = rest[1:]
+		if len(rest) == 0 {
+			// Trailing slash.
+			p.segments = append(p.segments, segment{wild: true, multi: true})
+			break
+		}
+		i := strings.IndexByte(rest, '/')
+		if i < 0 {
+			i = len(rest)
+		}
+		p.segments = append(p.segments, segment{s: rest[:i]})
+		rest = rest[i:]
+	}
+	return p, nil
+}
+
+// LiteralPattern returns a pattern that matches exactly the path made of the
+// given segments, and the given method if it is non-empty.
+// The segments are raw values, like slugs from a database. Bytes that may not
+// appear unescaped in a path are escaped, along with slashes, percent signs
+// and braces, so a segment may contain any character, and braces never form
+// wildcards. Bytes like '(' and '*' that are legal in a path are left alone,
+// because [net/url.URL.EscapedPath] preserves them as the client sent them.
+//
+// If the last segment is empty, the pattern matches only the path with a
+// trailing slash. With no segments, it matches only "/".
+// LiteralPattern panics if method is not a valid HTTP token or if a segment
+// other than the last is empty.
+func LiteralPattern(method string, segments ...string) string {
+	var b strings.Builder
+	if method != "" {
+		if !isValidHTTPToken(method) {
+			panic(fmt.Sprintf("bad method %q", method))
+		}
+		b.WriteString(method)
+		b.WriteByte(' ')
+	}
+	if len(segments) == 0 {
+		return b.String() + "/{$}"
+	}
+	for i, seg := range segments {
+		b.WriteByte('/')
+		if seg == "" {
+			if i != len(segments)-1 {
+				panic(fmt.Sprintf("empty segment at position %d", i))
+			}
+			b.WriteString("{$}")
+			break
+		}
+		b.WriteString(escapeSegment(seg))
+	}
+	return b.String()
+}
+
+// escapeSegment escapes a single path segment so that it matches a request
+// that sends the segment with only the necessary escaping.
+// [net/url.URL.EscapedPath] keeps the bytes that may appear unescaped in a
+// path as the client sent them, so escapeSegment leaves those alone too,
+// including sub-delimiters like '(' and '*'. It escapes every other byte,
+// as well as '/', '%' and braces. Dot segments are escaped so that path
+// cleaning does not remove them.
+func escapeSegment(seg string) string {
+	switch seg {
+	case ".":
+		return "%2E"
+	case "..":
+		return "%2E%2E"
+	}
+	var b strings.Builder
+	for i := 0; i < len(seg); i++ {
+		if c := seg[i]; isUnescapedPathByte(c) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// isUnescapedPathByte reports whether c may appear unescaped in a path
+// segment: it is unreserved, a sub-delimiter, ':' or '@' (RFC 3986,
+// Appendix A), or one of the brackets that net/url also accepts.
+func isUnescapedPathByte(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~!$&'()*+,;=:@[]", c) >= 0
+}
+
 var httpTokenRegexp = regexp.MustCompile("^[-0-9A-Za-z!#$%&'*+.^_`|~]+$")
 
 // See https://www.rfc-editor.org/rfc/rfc9110#section-5.6.2.
@@ -210,14 +384,20 @@ func isValidWildcardName(s string) bool {
 //
 // Precedence is defined by these rules:
 //
-//  1. Patterns with a host win over patterns without a host.
+//  1. Patterns with more specific hosts win. A pattern with a host wins
+//     over a pattern without one, and an exact host like "a.example.com"
+//     wins over a host glob like "*.example.com" that matches it, which
+//     wins over a shorter glob like "*.com".
 //  2. Patterns whose method and path is more specific win. One pattern is more
 //     specific than another if the second matches all the (method, path) pairs
 //     of the first and more.
 func (p1 *Pattern) HigherPrecedence(p2 *Pattern) bool {
-	// 1. Patterns with a host win over patterns without a host.
-	if (p1.host == "") != (p2.host == "") {
-		return p1.host != ""
+	// 1. Patterns with more specific hosts win.
+	switch compareHosts(p1.host, p2.host) {
+	case moreSpecific:
+		return true
+	case moreGeneral:
+		return false
 	}
 	// 2. More specific (method, path)s win.
 	return p1.comparePathsAndMethods(p2) == moreSpecific
@@ -228,15 +408,53 @@ func (p1 *Pattern) HigherPrecedence(p2 *Pattern) bool {
 // than the other.
 func (p1 *Pattern) ConflictsWith(p2 *Pattern) bool {
 	if p1.host != p2.host {
-		// Either one host is empty and the other isn't, in which case the
-		// one with the host wins by rule 1, or neither host is empty
-		// and they differ, so they won't match the same paths.
+		// Either one host is more specific than the other, in which case
+		// it wins by rule 1, or the hosts are disjoint, so they won't match
+		// the same requests.
 		return false
 	}
 	rel := p1.comparePathsAndMethods(p2)
 	return rel == equivalent || rel == overlaps
 }
 
+// isHostGlob reports whether host is a glob like "*.example.com".
+func isHostGlob(host string) bool {
+	return len(host) > 2 && host[0] == '*' && host[1] == '.'
+}
+
+// hostMatches reports whether the pattern host ph matches the request host h.
+func hostMatches(ph, h string) bool {
+	return ph == "" || ph == h || (isHostGlob(ph) && hostCovers(ph, h))
+}
+
+// hostCovers reports whether the host glob g matches every host
+// that the pattern host h matches.
+func hostCovers(g, h string) bool {
+	suffix := g[1:] // ".example.com"
+	h = strings.TrimPrefix(h, "*")
+	return len(h) > len(suffix) && strings.HasSuffix(h, suffix)
+}
+
+// compareHosts determines the relationship between two pattern hosts.
+// It returns moreSpecific if h1 matches a subset of the hosts that h2
+// matches, and so on.
+func compareHosts(h1, h2 string) relationship {
+	switch {
+	case h1 == h2:
+		return equivalent
+	case h1 == "":
+		return moreGeneral
+	case h2 == "":
+		return moreSpecific
+	case isHostGlob(h1) && hostCovers(h1, h2):
+		return moreGeneral
+	case isHostGlob(h2) && hostCovers(h2, h1):
+		return moreSpecific
+	default:
+		return disjoint
+	}
+}
+
 // relationship is a relationship between two patterns.
 type relationship string
 
@@ -418,6 +636,10 @@ func describeRel(p1, p2 *Pattern) string {
 			return fmt.Sprintf("%s does not have a host, while %s does, so %[2]s takes precedence", p1, p2)
 		case p2.host == "":
 			return fmt.Sprintf("%s does not have a host, while %s does, so %[2]s takes precedence", p2, p1)
+		case compareHosts(p1.host, p2.host) == moreSpecific:
+			return fmt.Sprintf("%s has a more specific host than %s, so %[1]s takes precedence", p1, p2)
+		case compareHosts(p1.host, p2.host) == moreGeneral:
+			return fmt.Sprintf("%s has a more specific host than %s, so %[1]s takes precedence", p2, p1)
 		default:
 			return fmt.Sprintf("%s and %s have different hosts, so they have no requests in common", p1, p2)
 		}
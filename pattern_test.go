@@ -7,6 +7,7 @@ package muxpatterns
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"regexp"
 	"strings"
 	"testing"
@@ -127,7 +128,12 @@ func TestParseError(t *testing.T) {
 		{"/{a...}/x", "not at end"},
 		{"{a}/b", "missing initial '/'"},
 		{"/a/{x}/b/{x...}", "duplicate wildcard name"},
-		{"GET //", "unclean path"},
+		{"a*.com/", "bad host glob"},
+		{"*/", "bad host glob"},
+		{"*./", "bad host glob"},
+		{"a.*.com/", "bad host glob"},
+		{"*.*.com/", "bad host glob"},
+		{"*.a*/", "bad host glob"},
 	} {
 		_, err := Parse(test.in)
 		if err == nil || !strings.Contains(err.Error(), test.contains) {
@@ -136,6 +142,129 @@ func TestParseError(t *testing.T) {
 	}
 }
 
+func TestLiteralPattern(t *testing.T) {
+	for _, test := range []struct {
+		method   string
+		segments []string
+		want     string
+		target   string // request target that should match
+	}{
+		{"", nil, "/{$}", "/"},
+		{"GET", []string{"posts", "hello-world"}, "GET /posts/hello-world", "/posts/hello-world"},
+		{"", []string{"{id}"}, "/%7Bid%7D", "/%7Bid%7D"},
+		{"", []string{"a/b", "c d"}, "/a%2Fb/c%20d", "/a%2Fb/c%20d"},
+		{"", []string{"100%", "caf\u00e9"}, "/100%25/caf%C3%A9", "/100%25/caf%C3%A9"},
+		{"", []string{"..", "."}, "/%2E%2E/%2E", "/%2E%2E/%2E"},
+		{"PUT", []string{"dir", ""}, "PUT /dir/{$}", "/dir/"},
+		{"GET", []string{"posts", "hello(world)"}, "GET /posts/hello(world)", "/posts/hello(world)"},
+		{"", []string{"it's", "a!b", "*"}, "/it's/a!b/*", "/it's/a!b/*"},
+		{"", []string{"a:b@c", "x=1;y=2", "[v]"}, "/a:b@c/x=1;y=2/[v]", "/a:b@c/x=1;y=2/[v]"},
+		{"", []string{"(a b)"}, "/(a%20b)", "/(a%20b)"},
+	} {
+		got := LiteralPattern(test.method, test.segments...)
+		if got != test.want {
+			t.Errorf("%q %q: got %q, want %q", test.method, test.segments, got, test.want)
+			continue
+		}
+		mux := NewServeMux()
+		mux.Handle(got, http.NotFoundHandler())
+		method := test.method
+		if method == "" {
+			method = "GET"
+		}
+		if _, pat := mux.Handler(httptest.NewRequest(method, test.target, nil)); pat != got {
+			t.Errorf("%s %s: matched %q, want %q", method, test.target, pat, got)
+		}
+	}
+}
+
+func TestExamplePath(t *testing.T) {
+	for _, test := range []struct {
+		pat, want string
+	}{
+		{"/", "/"},
+		{"GET /a/{x}/b", "/a/x/b"},
+		{"h.com/a/{$}", "/a/"},
+		{"/a/{rest...}", "/a/"},
+	} {
+		p := mustParse(t, test.pat)
+		got := p.ExamplePath()
+		if got != test.want {
+			t.Errorf("%q: got %q, want %q", test.pat, got, test.want)
+		}
+		if _, ok := p.referenceMatch(p.method, p.host, got); !ok {
+			t.Errorf("%q does not match its example path %q", test.pat, got)
+		}
+	}
+
+	// Legacy patterns match unescaped paths, but the example is escaped.
+	p, err := parseLegacy("/a b/%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.ExamplePath(), "/a%20b/%25"; got != want {
+		t.Errorf("legacy %q: got %q, want %q", p, got, want)
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+	for _, test := range []struct {
+		pat, prefix string
+		want        string // empty for error
+	}{
+		{"/", "/api", "/api/"},
+		{"GET /{id}", "/users", "GET /users/{id}"},
+		{"GET /{id}", "/users/", "GET /users/{id}"},
+		{"example.com/{$}", "/v1/{org}", "example.com/v1/{org}/{$}"},
+		{"POST /b/{rest...}", "/", "POST /b/{rest...}"},
+		{"/{id}", "/users/{id}", ""}, // duplicate wildcard
+		{"/a", "users", ""},
+		{"/a", "/{x...}", ""},
+		{"/a", "/b/{$}", ""},
+	} {
+		got, err := mustParse(t, test.pat).WithPrefix(test.prefix)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%q, %q: got %q, want error", test.pat, test.prefix, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q, %q: %v", test.pat, test.prefix, err)
+			continue
+		}
+		if got.String() != test.want {
+			t.Errorf("%q, %q: got %q, want %q", test.pat, test.prefix, got, test.want)
+		}
+		if want := mustParse(t, test.want); !got.equal(want) {
+			t.Errorf("%q, %q: got %#v, want %#v", test.pat, test.prefix, got, want)
+		}
+	}
+
+	// Legacy patterns stay legacy.
+	mux := NewServeMux(LegacyMode())
+	mux.Handle("/a b", http.NotFoundHandler())
+	got, err := mux.Routes()[0].WithPrefix("/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := got.String(), "/v1/a b"; g != w || !got.legacy {
+		t.Errorf("legacy: got %q (legacy %t), want %q", g, got.legacy, w)
+	}
+
+	// The result does not share scopes with the original.
+	p := mustParse(t, "/a")
+	WithAuth("read")(&p.route)
+	q, err := p.WithPrefix("/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.route.scopes[0] = "write"
+	if p.route.scopes[0] != "read" {
+		t.Error("WithPrefix result shares scopes with the original")
+	}
+}
+
 func (p1 *Pattern) equal(p2 *Pattern) bool {
 	return p1.method == p2.method && p1.host == p2.host && slices.Equal(p1.segments, p2.segments)
 }
@@ -436,6 +565,13 @@ func TestHigherPrecedence(t *testing.T) {
 		{"h/", "/", true},
 		{"/", "h/", false},
 		{"h/", "h/", false},
+		{"a.h.com/", "*.h.com/", true},
+		{"*.h.com/", "a.h.com/", false},
+		{"*.h.com/", "/", true},
+		{"/", "*.h.com/", false},
+		{"*.a.h.com/", "*.h.com/", true},
+		{"*.h.com/", "*.a.h.com/", false},
+		{"GET *.h.com/a", "a.h.com/", false}, // host first
 
 		// 2. method
 		{"GET /", "/", true},
@@ -571,6 +707,8 @@ func TestDescribeRelationship(t *testing.T) {
 		{"/", "/foo", "is more specific than"},
 		{"a.com/b", "/b", "does not have a host"},
 		{"a.com/b", "b.com/b", "different hosts"},
+		{"*.a.com/b", "x.a.com/b", "x.a.com/b has a more specific host"},
+		{"*.a.com/b", "*.b.com/b", "different hosts"},
 	} {
 		got := DescribeRelationship(test.p1, test.p2)
 		fmt.Println(got)
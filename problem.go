@@ -0,0 +1,81 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Errors as RFC 7807 problem details.
+
+package muxpatterns
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ProblemJSON returns an Option that causes the ServeMux to write the errors
+// it generates itself, such as 404 Not Found and 405 Method Not Allowed, as
+// "application/problem+json" documents as described in RFC 7807.
+//
+// The "type" member of each document is baseTypeURI followed by a slug
+// derived from the status text, for example
+// "https://example.com/problems/not-found". If baseTypeURI is empty, the
+// type is "about:blank", as the RFC recommends when no further
+// semantics are defined.
+//
+// Errors written by registered handlers are not affected.
+func ProblemJSON(baseTypeURI string) Option {
+	return func(mux *ServeMux) {
+		mux.problems = &problemConfig{baseTypeURI: baseTypeURI}
+	}
+}
+
+type problemConfig struct {
+	baseTypeURI string
+}
+
+// A problem is an RFC 7807 problem details document.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (pc *problemConfig) handler(code int, detail string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pc.write(w, code, detail)
+	})
+}
+
+func (pc *problemConfig) write(w http.ResponseWriter, code int, detail string) {
+	p := problem{
+		Type:   pc.typeURI(code),
+		Title:  http.StatusText(code),
+		Status: code,
+		Detail: detail,
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		// Cannot happen: all fields are strings and ints.
+		panic(err)
+	}
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/problem+json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(data)
+	w.Write([]byte{'\n'})
+}
+
+// typeURI returns the problem type URI for the status code.
+func (pc *problemConfig) typeURI(code int) string {
+	if pc.baseTypeURI == "" {
+		return "about:blank"
+	}
+	slug := strings.ToLower(strings.ReplaceAll(http.StatusText(code), " ", "-"))
+	if strings.HasSuffix(pc.baseTypeURI, "/") {
+		return pc.baseTypeURI + slug
+	}
+	return pc.baseTypeURI + "/" + slug
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package muxpatterns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblemJSON(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, test := range []struct {
+		base         string
+		method, path string
+		want         problem
+		wantAllow    string
+	}{
+		{
+			"https://example.com/problems", "GET", "/x",
+			problem{Type: "https://example.com/problems/not-found", Title: "Not Found", Status: 404},
+			"",
+		},
+		{
+			"https://example.com/problems/", "POST", "/g",
+			problem{
+				Type:   "https://example.com/problems/method-not-allowed",
+				Title:  "Method Not Allowed",
+				Status: 405,
+				Detail: "allowed methods: GET, HEAD",
+			},
+			"GET, HEAD",
+		},
+		{
+			"", "GET", "/x",
+			problem{Type: "about:blank", Title: "Not Found", Status: 404},
+			"",
+		},
+	} {
+		mux := NewServeMux(ProblemJSON(test.base))
+		mux.Handle("GET /g", h)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if g, w := w.Code, test.want.Status; g != w {
+			t.Errorf("%s %s: got status %d, want %d", test.method, test.path, g, w)
+		}
+		if g, w := w.Header().Get("Content-Type"), "application/problem+json"; g != w {
+			t.Errorf("%s %s: got Content-Type %q, want %q", test.method, test.path, g, w)
+		}
+		if g, w := w.Header().Get("Allow"), test.wantAllow; g != w {
+			t.Errorf("%s %s: got Allow %q, want %q", test.method, test.path, g, w)
+		}
+		var got problem
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%s %s: got %+v, want %+v", test.method, test.path, got, test.want)
+		}
+	}
+}
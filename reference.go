@@ -0,0 +1,228 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// A slow, simple matcher for checking the decision tree.
+
+package muxpatterns
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// CheckMatch is a testing aid for changes to the matching algorithm.
+// It registers patterns on a new ServeMux, skipping any that are invalid
+// or conflict with earlier ones. It then matches the request given by
+// method, host and path both with the ServeMux's decision tree and with a
+// reference matcher that tries every pattern and picks the one of highest
+// precedence. It returns a non-nil error describing the difference if the
+// two disagree on the matching pattern or the wildcard values.
+//
+// The path should be clean and escaped, as it would be after the ServeMux
+// canonicalizes it.
+//
+// CheckMatch is meant to be called from a fuzz test, typically with
+// arguments from [DecodeMatchInput].
+func CheckMatch(patterns []string, method, host, path string) error {
+	mux := NewServeMux()
+	var pats []*Pattern
+	for _, s := range patterns {
+		if err := mux.register(s, http.NotFoundHandler()); err != nil {
+			continue
+		}
+		p, err := Parse(s)
+		if err != nil {
+			return fmt.Errorf("%q registered but did not parse: %v", s, err)
+		}
+		pats = append(pats, p)
+	}
+	n, treeValues := mux.tree.match(method, host, path)
+	treePat := ""
+	if n != nil {
+		treePat = n.pattern.String()
+	}
+	refPat := ""
+	var refValues []string
+	if p, vals, err := referenceMatch(pats, method, host, path); err != nil {
+		return err
+	} else if p != nil {
+		refPat = p.String()
+		refValues = vals
+	}
+	if treePat != refPat || !slices.Equal(treeValues, refValues) {
+		return fmt.Errorf("%s %s%s with patterns %q:\ntree matched      %q %q\nreference matched %q %q",
+			method, host, path, patterns, treePat, treeValues, refPat, refValues)
+	}
+	return nil
+}
+
+// referenceMatch returns the pattern of highest precedence among pats that
+// matches the request, along with its wildcard values.
+// It returns an error if there is no single highest-precedence pattern.
+func referenceMatch(pats []*Pattern, method, host, path string) (*Pattern, []string, error) {
+	var matching []*Pattern
+	for _, p := range pats {
+		if _, ok := p.referenceMatch(method, host, path); ok {
+			matching = append(matching, p)
+		}
+	}
+	var best []*Pattern
+	for _, p1 := range matching {
+		beaten := false
+		for _, p2 := range matching {
+			if p2 != p1 && p2.HigherPrecedence(p1) {
+				beaten = true
+				break
+			}
+		}
+		if !beaten {
+			best = append(best, p1)
+		}
+	}
+	switch len(best) {
+	case 0:
+		return nil, nil, nil
+	case 1:
+		vals, _ := best[0].referenceMatch(method, host, path)
+		return best[0], vals, nil
+	default:
+		return nil, nil, fmt.Errorf("%s %s%s: no single pattern has highest precedence among %v", method, host, path, best)
+	}
+}
+
+// referenceMatch reports whether p matches the request, and if so
+// returns the values of its named wildcards.
+func (p *Pattern) referenceMatch(method, host, path string) ([]string, bool) {
+	if !hostMatches(p.host, host) {
+		return nil, false
+	}
+	if p.method != "" && p.method != method && !(p.method == "GET" && method == "HEAD") {
+		return nil, false
+	}
+	// After the initial slash, a path ending in a slash has an
+	// empty last element.
+	elems := strings.Split(path[1:], "/")
+	trailingSlash := strings.HasSuffix(path, "/")
+	var values []string
+	for i, seg := range p.segments {
+		if i >= len(elems) {
+			return nil, false
+		}
+		if seg.multi {
+			if seg.s != "" {
+				values = append(values, matchValue(strings.Join(elems[i:], "/")))
+			}
+			return values, true
+		}
+		elem := elems[i]
+		last := i == len(elems)-1
+		switch {
+		case seg.s == "/" && !seg.wild:
+			// "{$}" matches only the trailing slash.
+			if !last || !trailingSlash {
+				return nil, false
+			}
+		case seg.wild:
+			if last && trailingSlash {
+				return nil, false
+			}
+			values = append(values, matchValue(elem))
+		default:
+			if (last && trailingSlash) || elem != seg.s {
+				return nil, false
+			}
+		}
+	}
+	// The pattern is exhausted; the path must be too.
+	if len(p.segments) != len(elems) {
+		return nil, false
+	}
+	return values, true
+}
+
+var (
+	fuzzPatternMethods = []string{"", "GET", "HEAD", "POST"}
+	fuzzRequestMethods = []string{"GET", "HEAD", "POST", "PUT"}
+	fuzzPatternHosts   = []string{"", "a.com", "b.com", "*.a.com"}
+	fuzzRequestHosts   = []string{"", "a.com", "b.com", "x.a.com"}
+	fuzzLiterals       = []string{"a", "b", "c", "d"}
+)
+
+// DecodeMatchInput turns arbitrary bytes into arguments for [CheckMatch],
+// so that a fuzzer can explore sets of patterns and requests.
+// Every pattern it returns is syntactically valid, though the patterns
+// may conflict with each other.
+//
+// The input is divided into chunks by 0xFF bytes. The first chunk
+// describes the request and the others describe patterns. The first byte of
+// a chunk selects the method and host, and each remaining byte
+// contributes a path segment.
+func DecodeMatchInput(data []byte) (patterns []string, method, host, path string) {
+	chunks := bytes.Split(data, []byte{0xFF})
+	method, host, path = decodeRequest(chunks[0])
+	for _, c := range chunks[1:] {
+		patterns = append(patterns, decodePattern(c))
+	}
+	return patterns, method, host, path
+}
+
+func decodeRequest(c []byte) (method, host, path string) {
+	method = fuzzRequestMethods[0]
+	if len(c) > 0 {
+		method = fuzzRequestMethods[c[0]&3]
+		host = fuzzRequestHosts[int(c[0]>>2)%len(fuzzRequestHosts)]
+		c = c[1:]
+	}
+	var b strings.Builder
+	for _, x := range c {
+		b.WriteByte('/')
+		b.WriteString(fuzzLiterals[x&3])
+	}
+	// A set high bit in the last byte adds a trailing slash.
+	if len(c) == 0 || c[len(c)-1]&0x80 != 0 {
+		b.WriteByte('/')
+	}
+	return method, host, b.String()
+}
+
+func decodePattern(c []byte) string {
+	var b strings.Builder
+	if len(c) > 0 {
+		if m := fuzzPatternMethods[c[0]&3]; m != "" {
+			b.WriteString(m)
+			b.WriteByte(' ')
+		}
+		b.WriteString(fuzzPatternHosts[int(c[0]>>2)%len(fuzzPatternHosts)])
+		c = c[1:]
+	}
+	if len(c) == 0 {
+		b.WriteByte('/')
+		return b.String()
+	}
+	for i, x := range c {
+		last := i == len(c)-1
+		b.WriteByte('/')
+		switch v := x & 7; {
+		case v < 4:
+			b.WriteString(fuzzLiterals[v])
+		case v == 6 && last:
+			fmt.Fprintf(&b, "{x%d...}", i)
+			return b.String()
+		case v == 7 && last:
+			b.WriteString("{$}")
+			return b.String()
+		default:
+			fmt.Fprintf(&b, "{x%d}", i)
+		}
+	}
+	// A set high bit in the last byte adds a trailing slash.
+	if c[len(c)-1]&0x80 != 0 {
+		b.WriteByte('/')
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package muxpatterns
+
+import (
+	"testing"
+)
+
+func TestCheckMatch(t *testing.T) {
+	patterns := []string{
+		"/item/",
+		"POST /item/{user}",
+		"GET /item/{user}",
+		"/item/{user}",
+		"/item/{user}/{id}",
+		"/item/{user}/new",
+		"/item/{$}",
+		"POST alt.com/item/{user}",
+		"GET /headwins",
+		"HEAD /headwins",
+		"/path/{p...}",
+		"/path/{q}", // conflicts; skipped
+	}
+	for _, req := range []struct {
+		method, host, path string
+	}{
+		{"GET", "", "/item/jba"},
+		{"HEAD", "", "/item/jba"},
+		{"POST", "alt.com", "/item/jba"},
+		{"GET", "alt.com", "/item/jba"},
+		{"GET", "", "/item/jba/new"},
+		{"GET", "", "/item/jba/17/line2"},
+		{"GET", "", "/item/"},
+		{"GET", "", "/item"},
+		{"HEAD", "", "/headwins"},
+		{"GET", "", "/path/"},
+		{"GET", "", "/path/to/file/"},
+	} {
+		if err := CheckMatch(patterns, req.method, req.host, req.path); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDecodeMatchInput(t *testing.T) {
+	pats, method, host, path := DecodeMatchInput([]byte{
+		0x05, 0x00, 0x81, // request
+		0xFF, 0x01, 0x00, 0x04, // pattern
+		0xFF, 0x04, 0x82, // pattern
+		0xFF, 0x03, 0x06, // pattern
+		0xFF, 0x00, 0x01, 0x07, // pattern
+	})
+	if g, w := method+" "+host+path, "HEAD a.com/a/b/"; g != w {
+		t.Errorf("request: got %q, want %q", g, w)
+	}
+	want := []string{"GET /a/{x1}", "a.com/c/", "POST /{x0...}", "/b/{$}"}
+	if len(pats) != len(want) {
+		t.Fatalf("got %q, want %q", pats, want)
+	}
+	for i, p := range pats {
+		if p != want[i] {
+			t.Errorf("#%d: got %q, want %q", i, p, want[i])
+		}
+		if _, err := Parse(p); err != nil {
+			t.Errorf("#%d: %v", i, err)
+		}
+	}
+}
+
+func FuzzMatch(f *testing.F) {
+	f.Add([]byte{0x05, 0x00, 0x81, 0xFF, 0x01, 0x00, 0x04, 0xFF, 0x04, 0x82, 0xFF, 0x03, 0x06})
+	f.Add([]byte{0x01, 0x01, 0x81, 0xFF, 0x01, 0x01, 0x07, 0xFF, 0x00, 0x01, 0x86})
+	f.Add([]byte{0x00, 0x02, 0x02, 0xFF, 0x02, 0x02, 0x04, 0xFF, 0x00, 0x04, 0x02})
+	f.Add([]byte{0x00, 0x01, 0xFF, 0x00, 0x01, 0xFF, 0x01, 0x04})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pats, method, host, path := DecodeMatchInput(data)
+		if err := CheckMatch(pats, method, host, path); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Options for individual routes.
+
+package muxpatterns
+
+import (
+	"errors"
+	"net/http"
+)
+
+// A RouteOption configures a single pattern registered with
+// [ServeMux.HandleRoute].
+type RouteOption func(*route)
+
+// route holds the options a pattern was registered with.
+type route struct {
+	auth      bool      // requires authentication
+	scopes    []string  // scopes required in addition to authentication
+	validator Validator // if non-nil, checks requests before the handler
+}
+
+// WithAuth returns a RouteOption requiring that requests matching the
+// pattern be authenticated and granted the given scopes, as determined by
+// the ServeMux's [Authenticator], before the handler is called.
+// Registering a pattern with WithAuth on a ServeMux without an
+// Authenticator is an error.
+func WithAuth(scopes ...string) RouteOption {
+	return func(r *route) {
+		r.auth = true
+		r.scopes = append(r.scopes, scopes...)
+	}
+}
+
+// An Authenticator checks requests for routes registered with [WithAuth].
+type Authenticator interface {
+	// Authenticate is called after a request matches a route registered
+	// with WithAuth, and before the route's handler.
+	// It returns nil if the request is authenticated and has all the
+	// scopes. Otherwise, the ServeMux responds with 401 Unauthorized if the
+	// error wraps ErrUnauthenticated, and 403 Forbidden if not.
+	// Authenticate may set headers on w, such as WWW-Authenticate, but
+	// must not write the response.
+	// The request's path values are available via [PathValue].
+	Authenticate(w http.ResponseWriter, r *http.Request, scopes []string) error
+}
+
+// ErrUnauthenticated is returned, possibly wrapped, by an Authenticator
+// for a request without valid credentials.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Auth returns an Option that makes the ServeMux consult the Authenticator a
+// before serving routes registered with [WithAuth].
+func Auth(a Authenticator) Option {
+	return func(mux *ServeMux) {
+		mux.auth = a
+	}
+}
+
+// WithValidator returns a RouteOption that makes the ServeMux check each
+// request matching the pattern with v before calling the handler.
+// A validator is typically derived from the OpenAPI operation that
+// the pattern implements.
+func WithValidator(v Validator) RouteOption {
+	return func(r *route) {
+		r.validator = v
+	}
+}
+
+// A Validator checks a request before it reaches a route's handler.
+type Validator interface {
+	// Validate returns nil if the request is valid. Otherwise the
+	// ServeMux responds with 400 Bad Request, using the error's text as
+	// the details.
+	// The request's path values are available via [PathValue].
+	// A Validator that reads the request body must replace it so that
+	// the handler can read it too.
+	Validate(r *http.Request) error
+}
+
+// The ValidatorFunc type is an adapter to allow the use of ordinary
+// functions as Validators.
+type ValidatorFunc func(*http.Request) error
+
+// Validate calls f(r).
+func (f ValidatorFunc) Validate(r *http.Request) error {
+	return f(r)
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package muxpatterns
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testAuth grants the comma-separated scopes in the "Token" header.
+// The scope "self" is granted if the "user" path value equals the token.
+type testAuth struct{}
+
+func (testAuth) Authenticate(w http.ResponseWriter, r *http.Request, scopes []string) error {
+	tok := r.Header.Get("Token")
+	if tok == "" {
+		w.Header().Set("WWW-Authenticate", "Token")
+		return fmt.Errorf("no token: %w", ErrUnauthenticated)
+	}
+	granted := map[string]bool{}
+	for _, s := range strings.Split(tok, ",") {
+		granted[s] = true
+	}
+	granted["self"] = tok == PathValue(r, "user")
+	for _, s := range scopes {
+		if !granted[s] {
+			return errors.New("missing scope " + s)
+		}
+	}
+	return nil
+}
+
+func TestWithAuth(t *testing.T) {
+	mux := NewServeMux(Auth(testAuth{}))
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/public", h)
+	mux.HandleRoute("/private", h, WithAuth())
+	mux.HandleRoute("/admin", h, WithAuth("admin"))
+	mux.HandleRoute("/users/{user}", h, WithAuth("self"))
+	// The handler returned by Handler must check too.
+	viaHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, _ := mux.Handler(r)
+		h.ServeHTTP(w, r)
+	})
+
+	for _, test := range []struct {
+		path, token string
+		wantCode    int
+	}{
+		{"/public", "", 200},
+		{"/private", "", 401},
+		{"/private", "x", 200},
+		{"/admin", "", 401},
+		{"/admin", "x", 403},
+		{"/admin", "x,admin", 200},
+		{"/users/jba", "jba", 200},
+		{"/users/jba", "bob", 403},
+	} {
+		for _, s := range []http.Handler{mux, viaHandler} {
+			r := httptest.NewRequest("GET", test.path, nil)
+			if test.token != "" {
+				r.Header.Set("Token", test.token)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			if g, w := w.Code, test.wantCode; g != w {
+				t.Errorf("%T: %s, token %q: got %d, want %d", s, test.path, test.token, g, w)
+			}
+			if w.Code == 401 && w.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("%T: %s, token %q: missing WWW-Authenticate", s, test.path, test.token)
+			}
+		}
+	}
+}
+
+func TestWithAuthNoAuthenticator(t *testing.T) {
+	mux := NewServeMux()
+	err := mux.register("/private", http.NotFoundHandler(), WithAuth())
+	if err == nil || !strings.Contains(err.Error(), "no Authenticator") {
+		t.Errorf("got %v, want error about missing Authenticator", err)
+	}
+}
+
+func TestWithValidator(t *testing.T) {
+	var called bool
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+	validID := ValidatorFunc(func(r *http.Request) error {
+		id := PathValue(r, "id")
+		if strings.Trim(id, "0123456789") != "" {
+			return fmt.Errorf("path parameter id: %s is not an integer", id)
+		}
+		if r.URL.Query().Get("fields") == "" {
+			return errors.New("query parameter fields is required")
+		}
+		return nil
+	})
+	for _, opts := range [][]Option{nil, {ProblemJSON("")}} {
+		mux := NewServeMux(opts...)
+		mux.HandleRoute("/items/{id}", h, WithValidator(validID))
+		// The handler returned by Handler must validate too.
+		viaHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h, _ := mux.Handler(r)
+			h.ServeHTTP(w, r)
+		})
+		for _, test := range []struct {
+			target     string
+			wantCode   int
+			wantDetail string
+		}{
+			{"/items/12?fields=name", 200, ""},
+			{"/items/x?fields=name", 400, "path parameter id: x is not an integer"},
+			{"/items/12", 400, "query parameter fields is required"},
+		} {
+			for _, s := range []http.Handler{mux, viaHandler} {
+				called = false
+				w := httptest.NewRecorder()
+				s.ServeHTTP(w, httptest.NewRequest("GET", test.target, nil))
+				if g, w := w.Code, test.wantCode; g != w {
+					t.Errorf("%T: %s: got %d, want %d", s, test.target, g, w)
+				}
+				if g, w := called, test.wantCode == 200; g != w {
+					t.Errorf("%T: %s: handler called: got %t, want %t", s, test.target, g, w)
+				}
+				if !strings.Contains(w.Body.String(), test.wantDetail) {
+					t.Errorf("%T: %s: body %q does not contain %q", s, test.target, w.Body, test.wantDetail)
+				}
+			}
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"reflect"
 	"runtime"
 	"sort"
 	"strings"
@@ -29,16 +30,77 @@ import (
 // of this package.
 type ServeMux struct {
 	mu            sync.RWMutex
-	tree          *node
+	tree          *routingTree
 	conflictCalls atomic.Int32
 	index         *index
+
+	// Options.
+	problems *problemConfig // if non-nil, write errors as problem+json
+	cache    *matchCache    // if non-nil, cache of recent matches
+	legacy   bool           // behave exactly like net/http.ServeMux
+
+	canonicalize func(string) string // if nil, use CanonicalPath
+	noRedirect   bool                // match canonical paths without redirecting
+	maxURILength int                 // if positive, longer request targets get 414
+	auth         Authenticator       // for routes registered with WithAuth
+	drainable    bool                // count requests in flight for UnregisterAndDrain
+}
+
+// An Option configures a ServeMux.
+type Option func(*ServeMux)
+
+// LegacyMode returns an Option that makes the ServeMux behave exactly like
+// [net/http.ServeMux], so that a program can switch to this type before
+// adopting the enhanced patterns.
+//
+// In legacy mode, patterns with methods or wildcards, including "{$}",
+// are rejected when they are registered, and paths are matched after
+// unescaping, as net/http does. An empty path segment, as in "/a//b", must
+// match exactly, and a request is redirected to add a trailing slash if
+// neither its path nor its host and path is registered, but one of them is
+// with a trailing slash.
+func LegacyMode() Option {
+	return func(mux *ServeMux) {
+		mux.legacy = true
+	}
+}
+
+// MaxURILength returns an Option that makes the ServeMux reject requests
+// whose target is longer than n bytes with 414 URI Too Long, before any
+// other processing. If n is not positive, there is no limit.
+func MaxURILength(n int) Option {
+	return func(mux *ServeMux) {
+		mux.maxURILength = n
+	}
+}
+
+// PathCanonicalizer returns an Option that replaces [CanonicalPath] with f
+// for canonicalizing the paths of incoming requests. Patterns with a method
+// other than CONNECT must have paths that f leaves unchanged, since no
+// other path could match them.
+// The function is passed the escaped path of a request, and should return an
+// escaped path beginning with a slash.
+//
+// By default, the ServeMux redirects a request whose path is not canonical
+// to the canonical path. If redirect is false, it instead matches the
+// canonical path and serves the request without redirecting.
+// CONNECT requests are never canonicalized.
+func PathCanonicalizer(f func(path string) string, redirect bool) Option {
+	return func(mux *ServeMux) {
+		mux.canonicalize = f
+		mux.noRedirect = !redirect
+	}
 }
 
-func NewServeMux() *ServeMux {
-	return &ServeMux{
-		tree:  &node{},
+func NewServeMux(opts ...Option) *ServeMux {
+	mux := &ServeMux{
+		tree:  &routingTree{},
 		index: newIndex(),
 	}
+	for _, opt := range opts {
+		opt(mux)
+	}
+	return mux
 }
 
 func (mux *ServeMux) Handle(pattern string, handler http.Handler) {
@@ -54,7 +116,14 @@ func (mux *ServeMux) HandleFunc(pattern string, handler func(http.ResponseWriter
 	}
 }
 
-func (mux *ServeMux) register(pattern string, handler http.Handler) error {
+// HandleRoute is like Handle, but also applies options to the route.
+func (mux *ServeMux) HandleRoute(pattern string, handler http.Handler, opts ...RouteOption) {
+	if err := mux.register(pattern, handler, opts...); err != nil {
+		panic(err)
+	}
+}
+
+func (mux *ServeMux) register(pattern string, handler http.Handler, opts ...RouteOption) error {
 	if pattern == "" {
 		return errors.New("http: invalid pattern")
 	}
@@ -62,11 +131,24 @@ func (mux *ServeMux) register(pattern string, handler http.Handler) error {
 		return errors.New("http: nil handler")
 	}
 
-	pat, err := Parse(pattern)
+	pat, err := mux.parse(pattern)
 	if err != nil {
 		return err
 	}
+	// An unclean path with a method that is not CONNECT can never match,
+	// because paths are canonicalized before matching.
+	if pat.method != "" && pat.method != "CONNECT" {
+		if p := pat.path(); p != mux.canonicalPath(p) {
+			return fmt.Errorf("pattern %q: non-CONNECT pattern with unclean path can never match", pat)
+		}
+	}
 	pat.loc = callerLocation()
+	for _, opt := range opts {
+		opt(&pat.route)
+	}
+	if pat.route.auth && mux.auth == nil {
+		return fmt.Errorf("pattern %q requires authentication, but the ServeMux has no Authenticator", pat)
+	}
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
 	// Check for conflict.
@@ -75,9 +157,7 @@ func (mux *ServeMux) register(pattern string, handler http.Handler) error {
 		npats++
 		mux.conflictCalls.Add(1)
 		if pat.ConflictsWith(pat2) {
-			d := describeRel(pat, pat2)
-			return fmt.Errorf("pattern %q (registered at %s) conflicts with pattern %q (registered at %s):\n%s",
-				pat, pat.loc, pat2, pat2.loc, d)
+			return &ConflictError{Conflict{pat, pat2}}
 		}
 		return nil
 	}); err != nil {
@@ -85,43 +165,153 @@ func (mux *ServeMux) register(pattern string, handler http.Handler) error {
 	}
 	mux.tree.addPattern(pat, handler)
 	mux.index.addPattern(pat)
+	if mux.cache != nil {
+		mux.cache.clear()
+	}
 	return nil
 }
 
+// canonicalPath canonicalizes the escaped path p with the mux's
+// canonicalizer.
+func (mux *ServeMux) canonicalPath(p string) string {
+	if mux.canonicalize != nil {
+		return mux.canonicalize(p)
+	}
+	return CanonicalPath(p)
+}
+
+// parse parses a pattern according to the mux's mode.
+func (mux *ServeMux) parse(pattern string) (*Pattern, error) {
+	if mux.legacy {
+		return parseLegacy(pattern)
+	}
+	return Parse(pattern)
+}
+
+// callerLocation returns the location of the call that is registering
+// a pattern: the innermost caller that is not a ServeMux method.
 func callerLocation() string {
-	_, file, line, ok := runtime.Caller(2) // caller's caller's caller
-	if !ok {
-		return "unknown location"
+	var pcs [8]uintptr
+	n := runtime.Callers(3, pcs[:]) // skip Callers, callerLocation and register
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, muxMethodPrefix) {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return "unknown location"
+		}
 	}
-	return fmt.Sprintf("%s:%d", file, line)
+}
+
+var muxMethodPrefix = reflect.TypeOf((*ServeMux)(nil)).Elem().PkgPath() + ".(*ServeMux)."
+
+// Routes returns the patterns registered on mux, sorted by their strings.
+func (mux *ServeMux) Routes() []*Pattern {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	var pats []*Pattern
+	mux.tree.patterns(func(p *Pattern) { pats = append(pats, p) })
+	sort.Slice(pats, func(i, j int) bool { return pats[i].str < pats[j].str })
+	return pats
 }
 
 func (mux *ServeMux) Handler(r *http.Request) (h http.Handler, pattern string) {
-	h, _, sp, _ := mux.handler(r)
+	h, n, sp, _ := mux.handler(r)
+	if n != nil && mux.drainable {
+		h = mux.inFlightHandler(h, n)
+	}
 	return h, sp
 }
 
 type matchKey struct{}
 
 func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if mux.maxURILength > 0 && uriLength(r) > mux.maxURILength {
+		mux.error(w, http.StatusRequestURITooLong, "")
+		return
+	}
 	// This if statement copied from net/http/server.go.
 	if r.RequestURI == "*" {
 		if r.ProtoAtLeast(1, 1) {
 			w.Header().Set("Connection", "close")
 		}
+		if mux.problems != nil {
+			mux.problems.write(w, http.StatusBadRequest, "")
+			return
+		}
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	h, pat, _, matches := mux.handler(r)
+	h, n, _, matches := mux.handler(r)
+	if n != nil && mux.drainable {
+		if !n.acquire() {
+			// The pattern was unregistered after it matched.
+			// Match again.
+			mux.ServeHTTP(w, r)
+			return
+		}
+		defer n.release()
+	}
+	h.ServeHTTP(w, withMatch(r, n, matches))
+}
+
+// withMatch returns r with a context that holds the values matched by the
+// wildcards of n's pattern, for PathValue.
+func withMatch(r *http.Request, n *node, matches []string) *http.Request {
 	var m match
-	if pat != nil && matches != nil {
-		m = match{pat: pat, values: matches}
+	if n != nil && matches != nil {
+		m = match{pat: n.pattern, values: matches}
+	}
+	return r.WithContext(context.WithValue(r.Context(), matchKey{}, &m))
+}
+
+// routeHandler returns the handler for requests that match n's pattern.
+// If the pattern was registered with WithAuth or WithValidator, it is
+// n.handler wrapped with calls to the Authenticator and Validator, so that
+// the handler returned by [ServeMux.Handler] checks requests as ServeHTTP
+// does.
+func (mux *ServeMux) routeHandler(n *node, matches []string) http.Handler {
+	rt := &n.pattern.route
+	if !rt.auth && rt.validator == nil {
+		return n.handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Value(matchKey{}) == nil {
+			// Called through Handler rather than ServeHTTP.
+			r = withMatch(r, n, matches)
+		}
+		if rt.auth {
+			if err := mux.auth.Authenticate(w, r, rt.scopes); err != nil {
+				code := http.StatusForbidden
+				if errors.Is(err, ErrUnauthenticated) {
+					code = http.StatusUnauthorized
+				}
+				mux.error(w, code, "")
+				return
+			}
+		}
+		if rt.validator != nil {
+			if err := rt.validator.Validate(r); err != nil {
+				mux.error(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		n.handler.ServeHTTP(w, r)
+	})
+}
+
+// uriLength returns the length of the request target.
+func uriLength(r *http.Request) int {
+	if r.RequestURI != "" {
+		return len(r.RequestURI)
 	}
-	r = r.WithContext(context.WithValue(r.Context(), matchKey{}, &m))
-	h.ServeHTTP(w, r)
+	// The request was not read by a server; reconstruct the target.
+	return len(r.URL.RequestURI())
 }
 
-func (mux *ServeMux) handler(r *http.Request) (h http.Handler, pattern *Pattern, spat string, matches []string) {
+func (mux *ServeMux) handler(r *http.Request) (h http.Handler, leaf *node, spat string, matches []string) {
 	var (
 		n        *node
 		u        *url.URL
@@ -131,6 +321,10 @@ func (mux *ServeMux) handler(r *http.Request) (h http.Handler, pattern *Pattern,
 	)
 	host = r.URL.Host
 	escapedPath := r.URL.EscapedPath()
+	if mux.legacy {
+		// net/http.ServeMux matches against the unescaped path.
+		escapedPath = r.URL.Path
+	}
 	path = escapedPath
 	// CONNECT requests are not canonicalized.
 	if r.Method == "CONNECT" {
@@ -148,7 +342,7 @@ func (mux *ServeMux) handler(r *http.Request) (h http.Handler, pattern *Pattern,
 		// All other requests have any port stripped and path cleaned
 		// before passing to mux.handler.
 		host = stripHostPort(r.Host)
-		path = cleanPath(path)
+		path = mux.canonicalPath(path)
 
 		// If the given path is /tree and its handler is not registered,
 		// redirect for /tree/.
@@ -156,7 +350,7 @@ func (mux *ServeMux) handler(r *http.Request) (h http.Handler, pattern *Pattern,
 		if redirect {
 			return http.RedirectHandler(u.String(), http.StatusMovedPermanently), nil, u.Path, nil
 		}
-		if path != escapedPath {
+		if path != escapedPath && !mux.noRedirect {
 			// Redirect to cleaned path.
 			pattern := ""
 			if n != nil {
@@ -172,14 +366,38 @@ func (mux *ServeMux) handler(r *http.Request) (h http.Handler, pattern *Pattern,
 		// matches except for the method.
 		allowedMethods := mux.matchingMethods(host, path)
 		if len(allowedMethods) > 0 {
+			allow := strings.Join(allowedMethods, ", ")
 			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				w.Header().Set("Allow", strings.Join(allowedMethods, ", "))
-				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				w.Header().Set("Allow", allow)
+				if mux.problems != nil {
+					mux.problems.write(w, http.StatusMethodNotAllowed, "allowed methods: "+allow)
+					return
+				}
+				// Match net/http's plain response.
+				mux.error(w, http.StatusMethodNotAllowed, "")
 			}), nil, "", nil
 		}
+		if mux.problems != nil {
+			return mux.problems.handler(http.StatusNotFound, ""), nil, "", nil
+		}
 		return http.NotFoundHandler(), nil, "", nil
 	}
-	return n.handler, n.pattern, n.pattern.String(), matches
+	return mux.routeHandler(n, matches), n, n.pattern.String(), matches
+}
+
+// error writes an error response with the given status code, in the format
+// configured for mux. A non-empty detail follows the status text in a
+// plain-text response.
+func (mux *ServeMux) error(w http.ResponseWriter, code int, detail string) {
+	if mux.problems != nil {
+		mux.problems.write(w, code, detail)
+		return
+	}
+	msg := http.StatusText(code)
+	if detail != "" {
+		msg += ": " + detail
+	}
+	http.Error(w, msg, code)
 }
 
 func mightNeedCleaning(p string) bool {
@@ -194,8 +412,13 @@ func mightNeedCleaning(p string) bool {
 	return false
 }
 
-// cleanPath returns the canonical path for p, eliminating . and .. elements.
-func cleanPath(p string) string {
+// CanonicalPath returns the canonical path for p, eliminating . and .. elements
+// and repeated slashes. It preserves a trailing slash.
+//
+// By default, ServeMux redirects requests whose paths are not canonical to
+// the canonical path, so applications can use CanonicalPath to construct
+// links that the ServeMux will not redirect.
+func CanonicalPath(p string) string {
 	if p == "" {
 		return "/"
 	}
@@ -239,7 +462,14 @@ func (mux *ServeMux) matchOrRedirect(method, host, path string, u *url.URL) (*no
 	// on the same set of registered patterns.
 	mux.mu.RLock()
 	defer mux.mu.RUnlock()
-	n, matches := mux.tree.match(method, host, path)
+	if mux.legacy {
+		if u != nil && mux.shouldRedirectLegacy(host, path) {
+			return nil, nil, &url.URL{Path: path + "/", RawQuery: u.RawQuery}, true
+		}
+		n, matches := mux.match(method, host, path)
+		return n, matches, nil, false
+	}
+	n, matches := mux.match(method, host, path)
 	// If we have an exact match, then don't redirect.
 	if !exactMatch(n, path) && u != nil {
 		// If there is an exact match with a trailing slash, then redirect.
@@ -252,6 +482,57 @@ func (mux *ServeMux) matchOrRedirect(method, host, path string, u *url.URL) (*no
 	return n, matches, nil, false
 }
 
+// shouldRedirectLegacy reports whether net/http.ServeMux would redirect
+// host and path to path with a trailing slash: neither path nor host+path is
+// registered, but one of them is with a trailing slash.
+// The caller must hold mux.mu.
+func (mux *ServeMux) shouldRedirectLegacy(host, path string) bool {
+	hosts := []string{""}
+	if host != "" {
+		hosts = append(hosts, host)
+	}
+	for _, h := range hosts {
+		if mux.registeredLegacy(h, path) {
+			return false
+		}
+	}
+	if path == "" || path[len(path)-1] == '/' {
+		return false
+	}
+	for _, h := range hosts {
+		if mux.registeredLegacy(h, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// registeredLegacy reports whether the legacy pattern host+path is registered.
+// The caller must hold mux.mu.
+func (mux *ServeMux) registeredLegacy(host, path string) bool {
+	n := mux.tree.child(childKey{s: host, wild: host == ""})
+	if n == nil {
+		return false
+	}
+	// Legacy patterns have no method.
+	return exactMatch(n.emptyChild.matchPath(path, 0), path)
+}
+
+// match calls mux.tree.match, consulting the cache if there is one.
+// The caller must hold mux.mu.
+func (mux *ServeMux) match(method, host, path string) (*node, []string) {
+	if mux.cache == nil {
+		return mux.tree.match(method, host, path)
+	}
+	key := cacheKey{method, host, path}
+	if n, matches, ok := mux.cache.get(key); ok {
+		return n, matches
+	}
+	n, matches := mux.tree.match(method, host, path)
+	mux.cache.put(key, n, matches)
+	return n, matches
+}
+
 // exactMatch reports whether the node's pattern exactly matches the path.
 func exactMatch(n *node, path string) bool {
 	if n == nil {
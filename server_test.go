@@ -7,6 +7,7 @@ package muxpatterns
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -77,6 +78,194 @@ func TestServeMuxHandler(t *testing.T) {
 	}
 }
 
+func TestLegacyMode(t *testing.T) {
+	mux := NewServeMux(LegacyMode())
+	hmux := http.NewServeMux()
+	for i, pat := range []string{
+		"/",
+		"/foo/",
+		"/foo",
+		"/a b",
+		"/x/y/",
+		"example.com/foo/",
+		"other.com/",
+		"/x//y",
+		"h.com/",
+		"/e/",
+		"/a/*",
+		"/a/",
+	} {
+		h := &handler{i}
+		mux.Handle(pat, h)
+		hmux.Handle(pat, h)
+	}
+
+	for _, test := range []struct {
+		method, host, target string
+	}{
+		{"GET", "example.com", "/"},
+		{"GET", "example.com", "/foo"},
+		{"GET", "example.com", "/foo/bar"},
+		{"GET", "example.com:8080", "/foo/bar"},
+		{"GET", "other.com", "/foo"},
+		{"GET", "example.org", "/foo/bar"},
+		{"GET", "example.org", "/foo%2Fbar"},
+		{"GET", "example.org", "/a%20b"},
+		{"POST", "example.org", "/a%20b?q=1"},
+		{"GET", "example.org", "/x/y"},
+		{"GET", "example.org", "/x/y?q=1"},
+		{"GET", "example.org", "/x/./y/z"},
+		{"GET", "example.org", "/x//y/"},
+		{"GET", "example.org", "/x%2Fy"},
+		{"CONNECT", "example.org", "/foo"},
+		{"GET", "example.org", "/x/a/y"},
+		{"GET", "h.com", "/e"},
+		{"GET", "h.com", "/e/"},
+		{"GET", "h.com", "/e/f"},
+		{"GET", "example.org", "/a/xyz"},
+		{"GET", "example.org", "/a/*"},
+		{"GET", "example.org", "/a/"},
+	} {
+		r := httptest.NewRequest(test.method, test.target, nil)
+		r.Host = test.host
+		gotH, gotPat := mux.Handler(r)
+		wantH, wantPat := hmux.Handler(r)
+		got := fmt.Sprintf("%#v %q", gotH, gotPat)
+		want := fmt.Sprintf("%#v %q", wantH, wantPat)
+		if got != want {
+			t.Errorf("%s %s%s: got %s, want %s", test.method, test.host, test.target, got, want)
+		}
+	}
+}
+
+func TestLegacyModeRejects(t *testing.T) {
+	mux := NewServeMux(LegacyMode())
+	for _, pat := range []string{
+		"GET /",
+		"/{x}",
+		"/a/{$}",
+		"/a/{rest...}",
+		"noslash",
+	} {
+		if err := mux.register(pat, &handler{}); err == nil {
+			t.Errorf("%q: got nil error, want non-nil", pat)
+		}
+	}
+}
+
+func TestCanonicalPath(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"", "/"},
+		{"a/b", "/a/b"},
+		{"/a/b/", "/a/b/"},
+		{"//a///b", "/a/b"},
+		{"/a/./b/../c/", "/a/c/"},
+		{"/..", "/"},
+	} {
+		if got := CanonicalPath(test.in); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestPathCanonicalizer(t *testing.T) {
+	collapseSlashes := func(p string) string {
+		for strings.Contains(p, "//") {
+			p = strings.ReplaceAll(p, "//", "/")
+		}
+		return p
+	}
+	identity := func(p string) string { return p }
+
+	for _, test := range []struct {
+		name     string
+		opt      Option
+		path     string
+		wantCode int
+		wantPat  string
+	}{
+		{"default", nil, "//a/b", 301, ""},
+		{"collapse", PathCanonicalizer(collapseSlashes, false), "//a//b", 200, "/a/b"},
+		{"collapse-redirect", PathCanonicalizer(collapseSlashes, true), "//a//b", 301, ""},
+		{"collapse-dots", PathCanonicalizer(collapseSlashes, false), "/a/../a/b", 200, "/a/{rest...}"},
+		{"proxy", PathCanonicalizer(identity, true), "/a/./b", 200, "/a/{rest...}"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var opts []Option
+			if test.opt != nil {
+				opts = append(opts, test.opt)
+			}
+			mux := NewServeMux(opts...)
+			var gotPat string
+			for _, pat := range []string{"/a/b", "/a/{rest...}"} {
+				pat := pat
+				mux.HandleFunc("GET "+pat, func(w http.ResponseWriter, r *http.Request) {
+					gotPat = pat
+				})
+			}
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/", nil)
+			r.URL.Path = test.path
+			mux.ServeHTTP(w, r)
+			if g, w := w.Code, test.wantCode; g != w {
+				t.Errorf("got code %d, want %d", g, w)
+			}
+			if g, w := gotPat, test.wantPat; g != w {
+				t.Errorf("got pattern %q, want %q", g, w)
+			}
+		})
+	}
+
+	// Patterns with methods are checked with the mux's canonicalizer.
+	if err := NewServeMux().register("GET /a/./b", &handler{}); err == nil {
+		t.Error("default: unclean pattern registered")
+	}
+	mux := NewServeMux(PathCanonicalizer(identity, false))
+	mux.Handle("GET /a/./b", &handler{1})
+	if _, pat := mux.Handler(httptest.NewRequest("GET", "/a/./b", nil)); pat != "GET /a/./b" {
+		t.Errorf("proxy: got pattern %q, want %q", pat, "GET /a/./b")
+	}
+}
+
+func TestMaxURILength(t *testing.T) {
+	mux := NewServeMux(MaxURILength(10))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	for _, test := range []struct {
+		target   string
+		wantCode int
+	}{
+		{"/", 200},
+		{"/123456789", 200},
+		{"/1234567890", 414},
+		{"/?q=1234567", 414},
+		{"/" + strings.Repeat("../", 1000), 414},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", test.target, nil))
+		if g, w := w.Code, test.wantCode; g != w {
+			t.Errorf("%q: got %d, want %d", test.target, g, w)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	mux := NewServeMux()
+	pats := []string{"/b", "GET /a/{x}", "/", "h.com/a/", "/a/{x}/{$}"}
+	for _, p := range pats {
+		mux.Handle(p, http.NotFoundHandler())
+	}
+	var got []string
+	for _, p := range mux.Routes() {
+		got = append(got, p.String())
+	}
+	want := "/ /a/{x}/{$} /b GET /a/{x} h.com/a/"
+	if g := strings.Join(got, " "); g != want {
+		t.Errorf("got %q, want %q", g, want)
+	}
+}
+
 func TestExactMatch(t *testing.T) {
 	for _, test := range []struct {
 		pattern string
@@ -269,6 +458,17 @@ func TestStatus(t *testing.T) {
 		if g, w := res.Header.Get("Allow"), test.wantAllow; g != w {
 			t.Errorf("%s %s, Allow: got %q, want %q", test.method, test.path, g, w)
 		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The plain 405 body is the same as net/http's.
+		if test.wantStatus == 405 {
+			if g, w := string(body), "Method Not Allowed\n"; g != w {
+				t.Errorf("%s %s, body: got %q, want %q", test.method, test.path, g, w)
+			}
+		}
 	}
 }
 
@@ -526,6 +726,22 @@ func BenchmarkServeHTTP(b *testing.B) {
 			}
 		}
 	})
+	b.Run("muxpatterns-cache", func(b *testing.B) {
+		s := NewServeMux(MatchCache(len(patterns)))
+		for _, p := range patterns {
+			s.HandleFunc(p, httpHandlerFunc)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, p := range patterns {
+				r.RequestURI = p
+				u.Path = p
+				u.RawQuery = rq
+				s.ServeHTTP(w, r)
+			}
+		}
+	})
 }
 
 func httpHandlerFunc(_ http.ResponseWriter, _ *http.Request) {}
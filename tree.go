@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 )
 
 // A node is a node in the decision tree.
@@ -20,25 +21,54 @@ type node struct {
 	// with.
 	pattern *Pattern
 	handler http.Handler
+	// A leaf also tracks requests in flight, so that unregistering its
+	// pattern can wait for them. See drain.go.
+	inflight atomic.Int64
+	drained  chan struct{} // closed when removed and no requests are in flight
 
 	// An interior node maps parts of the incoming request to child nodes.
 	// special children keys:
 	//     "/"	trailing slash (resulting from {$})
 	//	   ""   single wildcard
-	//	   "*"  multi wildcard
 	children   mapping[string, *node]
 	emptyChild *node // optimization: child with key ""
+	// The multi wildcard has its own field, so that a literal "*" segment,
+	// allowed in legacy patterns, is an ordinary child.
+	multiChild *node
 }
 
-func (root *node) addPattern(p *Pattern, h http.Handler) {
+// A routingTree is the root of the decision tree. The children of its node
+// are keyed by host.
+type routingTree struct {
+	node
+	// Children for host globs, keyed by the part of the glob after the "*",
+	// such as ".example.com".
+	hostGlobs mapping[string, *node]
+}
+
+func (root *routingTree) addPattern(p *Pattern, h http.Handler) {
 	// First level of tree is host.
-	n := root.addChild(p.host)
+	var n *node
+	if isHostGlob(p.host) {
+		n = root.addHostGlob(p.host[1:])
+	} else {
+		n = root.addChild(p.host)
+	}
 	// Second level of tree is method.
 	n = n.addChild(p.method)
 	// Remaining levels are path.
 	n.addSegments(p.segments, p, h)
 }
 
+func (root *routingTree) addHostGlob(suffix string) *node {
+	if c, _ := root.hostGlobs.find(suffix); c != nil {
+		return c
+	}
+	c := &node{}
+	root.hostGlobs.add(suffix, c)
+	return c
+}
+
 func (n *node) addSegments(segs []segment, p *Pattern, h http.Handler) {
 	if len(segs) == 0 {
 		n.set(p, h)
@@ -49,15 +79,116 @@ func (n *node) addSegments(segs []segment, p *Pattern, h http.Handler) {
 		if len(segs) != 1 {
 			panic("multi wildcard not last")
 		}
-		if n.findChild("*") != nil {
+		if n.multiChild != nil {
 			panic("dup multi wildcards")
 		}
-		c := n.addChild("*")
-		c.set(p, h)
+		n.multiChild = &node{}
+		n.multiChild.set(p, h)
 	} else if seg.wild {
 		n.addChild("").addSegments(segs[1:], p, h)
 	} else {
-		n.addChild(seg.s).addSegments(segs[1:], p, h)
+		n.addLiteral(seg.s).addSegments(segs[1:], p, h)
+	}
+}
+
+// removePattern removes the pattern whose string is p.String() from the tree,
+// along with any nodes left empty. It returns the leaf that held the pattern,
+// or nil if there is no such pattern.
+func (root *routingTree) removePattern(p *Pattern) *node {
+	keys := []childKey{{s: p.host, wild: p.host == ""}, {s: p.method, wild: p.method == ""}}
+	for _, seg := range p.segments {
+		switch {
+		case seg.multi:
+			keys = append(keys, childKey{multi: true})
+		case seg.wild:
+			keys = append(keys, childKey{wild: true})
+		default:
+			keys = append(keys, childKey{s: seg.s})
+		}
+	}
+	if isHostGlob(p.host) {
+		suffix := p.host[1:]
+		c, _ := root.hostGlobs.find(suffix)
+		if c == nil {
+			return nil
+		}
+		leaf := c.removeKeys(keys[1:], p.String())
+		if c.isEmpty() {
+			root.hostGlobs.remove(suffix)
+		}
+		return leaf
+	}
+	return root.removeKeys(keys, p.String())
+}
+
+// A childKey identifies a child of a node: the empty child if wild is true,
+// the multi child if multi is true, and otherwise the child with key s.
+// It distinguishes literal segments in legacy patterns, like the "" in
+// "/a//b" and the "*" in "/a/*", from wildcards.
+type childKey struct {
+	s     string
+	wild  bool
+	multi bool
+}
+
+func (n *node) removeKeys(keys []childKey, pattern string) *node {
+	if len(keys) == 0 {
+		if n.pattern == nil || n.pattern.String() != pattern {
+			return nil
+		}
+		return n
+	}
+	key := keys[0]
+	c := n.child(key)
+	if c == nil {
+		return nil
+	}
+	leaf := c.removeKeys(keys[1:], pattern)
+	if leaf == nil {
+		return nil
+	}
+	if c == leaf {
+		// Detach the leaf's contents, but leave leaf.pattern and
+		// leaf.handler intact for requests already being served.
+		c = &node{children: leaf.children, emptyChild: leaf.emptyChild, multiChild: leaf.multiChild}
+		n.replaceChild(key, c)
+	}
+	if c.isEmpty() {
+		n.replaceChild(key, nil)
+	}
+	return leaf
+}
+
+// isEmpty reports whether n has no pattern and no children.
+func (n *node) isEmpty() bool {
+	return n.pattern == nil && n.emptyChild == nil && n.multiChild == nil && n.children.len() == 0
+}
+
+// child returns the child with the given key.
+func (n *node) child(key childKey) *node {
+	switch {
+	case key.wild:
+		return n.emptyChild
+	case key.multi:
+		return n.multiChild
+	}
+	return n.findChild(key.s)
+}
+
+// replaceChild replaces the child with the given key by c,
+// or removes it if c is nil.
+func (n *node) replaceChild(key childKey, c *node) {
+	switch {
+	case key.wild:
+		n.emptyChild = c
+		return
+	case key.multi:
+		n.multiChild = c
+		return
+	}
+	n.children.remove(key.s)
+	if c != nil {
+		n.children.add(key.s, c)
 	}
 }
 
@@ -84,6 +215,17 @@ func (n *node) addChild(key string) *node {
 	return c
 }
 
+// addLiteral is like addChild, but it keeps an empty literal segment in
+// children, apart from the single wildcard.
+func (n *node) addLiteral(key string) *node {
+	if c := n.findChild(key); c != nil {
+		return c
+	}
+	c := &node{}
+	n.children.add(key, c)
+	return c
+}
+
 func (n *node) findChild(key string) *node {
 	r, _ := n.children.find(key)
 	return r
@@ -94,7 +236,7 @@ func (n *node) findChild(key string) *node {
 // wildcards appear.
 //
 // If method is empty, the
-func (root *node) match(method, host, path string) (*node, []string) {
+func (root *routingTree) match(method, host, path string) (*node, []string) {
 	if host != "" {
 		// There is a host. If there is a pattern that specifies that host and it
 		// matches, we are done. If the pattern doesn't match, fall through to
@@ -102,6 +244,18 @@ func (root *node) match(method, host, path string) (*node, []string) {
 		if p, m := root.findChild(host).matchMethodAndPath(method, path); p != nil {
 			return p, m
 		}
+		// Then try host globs, most specific first.
+		if root.hostGlobs.len() > 0 {
+			for i := 1; i < len(host); i++ {
+				if host[i] != '.' {
+					continue
+				}
+				c, _ := root.hostGlobs.find(host[i:])
+				if p, m := c.matchMethodAndPath(method, path); p != nil {
+					return p, m
+				}
+			}
+		}
 	}
 	return root.emptyChild.matchMethodAndPath(method, path)
 }
@@ -110,58 +264,96 @@ func (n *node) matchMethodAndPath(method, path string) (*node, []string) {
 	if n == nil {
 		return nil, nil
 	}
-	if p, m := n.findChild(method).matchPath(path, nil); p != nil {
-		// Exact match of method name.
-		return p, m
-	}
-	if method == "HEAD" {
+	c := n.findChild(method).matchPath(path, 0)
+	if c == nil && method == "HEAD" {
 		// GET matches HEAD too.
-		if p, m := n.findChild("GET").matchPath(path, nil); p != nil {
-			return p, m
-		}
+		c = n.findChild("GET").matchPath(path, 0)
 	}
-	return n.emptyChild.matchPath(path, nil)
+	if c == nil {
+		c = n.emptyChild.matchPath(path, 0)
+	}
+	if c == nil {
+		return nil, nil
+	}
+	return c, wildcardValues(c.pattern, path)
 }
 
-func (n *node) matchPath(path string, matches []string) (*node, []string) {
+// matchPath returns the leaf node below n that matches path[i:].
+// If i < len(path), then path[i] is a slash.
+//
+// matchPath does not record wildcard values, so that no allocations happen on
+// paths through the tree that turn out not to match. Call wildcardValues on the
+// pattern of the result to obtain them.
+func (n *node) matchPath(path string, i int) *node {
 	if n == nil {
-		return nil, nil
+		return nil
 	}
-	// If path is empty, then return the node, whose pattern may be nil.
-	if path == "" {
+	// If path is exhausted, then return the node if it is a leaf.
+	if i == len(path) {
 		if n.pattern == nil {
-			return nil, nil
+			return nil
 		}
-		return n, matches
+		return n
 	}
-	seg, rest := nextSegment(path)
+	seg, next := nextSegment(path, i)
 	// Match literal.
-	if n, m := n.findChild(seg).matchPath(rest, matches); n != nil {
-		return n, m
+	if c := n.findChild(seg).matchPath(path, next); c != nil {
+		return c
 	}
 	// Match single wildcard, but not on a trailing slash.
 	if seg != "/" {
-		if n, m := n.emptyChild.matchPath(rest, append(matches, matchValue(seg))); n != nil {
-			return n, m
+		if c := n.emptyChild.matchPath(path, next); c != nil {
+			return c
 		}
 	}
-	// Match multi wildcard to the rest of the pattern.
-	if c := n.findChild("*"); c != nil {
-		// Don't record a match for a nameless wildcard (which arises from a
-		// trailing slash in the pattern).
-		if c.pattern.lastSegment().s != "" {
-			matches = append(matches, matchValue(path[1:])) // remove initial slash
+	// Match multi wildcard to the rest of the path.
+	return n.multiChild
+}
+
+// wildcardValues returns the values of the named wildcards of p
+// when it matches path, in the order that the wildcards appear.
+// It assumes p matches path.
+func wildcardValues(p *Pattern, path string) []string {
+	nwild := 0
+	for _, seg := range p.segments {
+		if seg.wild && seg.s != "" {
+			nwild++
+		}
+	}
+	if nwild == 0 {
+		return nil
+	}
+	values := make([]string, 0, nwild)
+	i := 0
+	for _, seg := range p.segments {
+		if seg.multi {
+			// Don't record a match for a nameless wildcard (which arises from a
+			// trailing slash in the pattern).
+			if seg.s != "" {
+				values = append(values, matchValue(path[i+1:])) // remove initial slash
+			}
+			break
+		}
+		var s string
+		s, i = nextSegment(path, i)
+		if seg.wild {
+			values = append(values, matchValue(s))
 		}
-		return c, matches
 	}
-	return nil, nil
+	return values
 }
 
 // matchingMethods returns a sorted list of all methods that, if passed to node.match
 // with the given host and path, would result in a match.
-func (root *node) matchingMethods(host, path string, methodSet map[string]bool) {
+func (root *routingTree) matchingMethods(host, path string, methodSet map[string]bool) {
 	if host != "" {
 		root.findChild(host).matchingMethodsPath(path, methodSet)
+		for i := 1; i < len(host); i++ {
+			if host[i] == '.' {
+				c, _ := root.hostGlobs.find(host[i:])
+				c.matchingMethodsPath(path, methodSet)
+			}
+		}
 	}
 	root.emptyChild.matchingMethodsPath(path, methodSet)
 	if methodSet["GET"] {
@@ -174,7 +366,7 @@ func (n *node) matchingMethodsPath(path string, set map[string]bool) {
 		return
 	}
 	n.children.pairs(func(method string, c *node) bool {
-		if p, _ := c.matchPath(path, nil); p != nil {
+		if c.matchPath(path, 0) != nil {
 			set[method] = true
 		}
 		return true
@@ -184,18 +376,45 @@ func (n *node) matchingMethodsPath(path string, set map[string]bool) {
 	// call this when we fail to match on a method.
 }
 
-// returns segment, "/" for trailing slash, or "" for done.
-// path should start with a "/"
-func nextSegment(path string) (seg, rest string) {
-	if path == "/" {
-		return "/", ""
+// patterns calls f on the pattern of every leaf in the tree rooted at n.
+func (n *node) patterns(f func(*Pattern)) {
+	if n == nil {
+		return
+	}
+	if n.pattern != nil {
+		f(n.pattern)
+	}
+	n.emptyChild.patterns(f)
+	n.multiChild.patterns(f)
+	n.children.pairs(func(_ string, c *node) bool {
+		c.patterns(f)
+		return true
+	})
+}
+
+// patterns calls f on the pattern of every leaf in the tree,
+// including those under host globs.
+func (root *routingTree) patterns(f func(*Pattern)) {
+	root.node.patterns(f)
+	root.hostGlobs.pairs(func(_ string, c *node) bool {
+		c.patterns(f)
+		return true
+	})
+}
+
+// nextSegment returns the path segment beginning at path[i], which must be a
+// slash, and the index just past it.
+// The segment is "/" for a trailing slash.
+func nextSegment(path string, i int) (seg string, next int) {
+	i++ // skip slash
+	if i == len(path) {
+		return "/", i
 	}
-	path = path[1:] // drop initial slash
-	i := strings.IndexByte(path, '/')
-	if i < 0 {
-		return path, ""
+	j := strings.IndexByte(path[i:], '/')
+	if j < 0 {
+		return path[i:], len(path)
 	}
-	return path[:i], path[i:]
+	return path[i : i+j], i + j
 }
 
 func matchValue(path string) string {
@@ -23,12 +23,12 @@ func TestNextSegment(t *testing.T) {
 		{"/a/b/c", []string{"a", "b", "c"}},
 		{"/a/b/", []string{"a", "b", "/"}},
 		{"/", []string{"/"}},
+		{"//a", []string{"", "a"}},
 	} {
 		var got []string
-		rest := test.in
-		for len(rest) > 0 {
+		for i := 0; i < len(test.in); {
 			var seg string
-			seg, rest = nextSegment(rest)
+			seg, i = nextSegment(test.in, i)
 			got = append(got, seg)
 		}
 		if !slices.Equal(got, test.want) {
@@ -38,9 +38,9 @@ func TestNextSegment(t *testing.T) {
 }
 
 // TODO: test host and method
-var testTree *node
+var testTree *routingTree
 
-func getTestTree() *node {
+func getTestTree() *routingTree {
 	if testTree == nil {
 		testTree = buildTree("/a", "/a/b", "/a/{x}",
 			"/g/h/i", "/g/{x}/j",
@@ -49,8 +49,8 @@ func getTestTree() *node {
 	return testTree
 }
 
-func buildTree(pats ...string) *node {
-	root := &node{}
+func buildTree(pats ...string) *routingTree {
+	root := &routingTree{}
 	for _, p := range pats {
 		pat, err := Parse(p)
 		if err != nil {
@@ -101,7 +101,7 @@ type testCase struct {
 
 func TestNodeMatch(t *testing.T) {
 
-	test := func(tree *node, tests []testCase) {
+	test := func(tree *routingTree, tests []testCase) {
 		t.Helper()
 		for _, test := range tests {
 			gotNode, gotMatches := tree.match(test.method, test.host, test.path)
@@ -210,11 +210,44 @@ func TestNodeMatch(t *testing.T) {
 	})
 }
 
+func TestHostGlobMatch(t *testing.T) {
+	tree := buildTree(
+		"/a",
+		"*.example.com/a",
+		"*.b.example.com/a",
+		"c.b.example.com/a",
+		"*.example.com/z/{x...}",
+	)
+	for _, test := range []struct {
+		host, path string
+		want       string
+	}{
+		{"example.com", "/a", "/a"},
+		{"x.example.com", "/a", "*.example.com/a"},
+		{"x.y.example.com", "/a", "*.example.com/a"},
+		{"b.example.com", "/a", "*.example.com/a"},
+		{"x.b.example.com", "/a", "*.b.example.com/a"},
+		{"c.b.example.com", "/a", "c.b.example.com/a"},
+		{"c.b.example.com", "/z/q", "*.example.com/z/{x...}"},
+		{".example.com", "/a", "/a"},
+		{"example.org", "/a", "/a"},
+	} {
+		n, _ := tree.match("GET", test.host, test.path)
+		got := ""
+		if n != nil {
+			got = n.pattern.String()
+		}
+		if got != test.want {
+			t.Errorf("%s%s: got %q, want %q", test.host, test.path, got, test.want)
+		}
+	}
+}
+
 func TestMatchingMethods(t *testing.T) {
 	hostTree := buildTree("GET a.com/", "PUT b.com/", "POST /foo/{x}")
 	for _, test := range []struct {
 		name       string
-		tree       *node
+		tree       *routingTree
 		host, path string
 		want       string
 	}{
@@ -248,6 +281,11 @@ func TestMatchingMethods(t *testing.T) {
 			hostTree, "b.com", "/bar",
 			"PUT",
 		},
+		{
+			"glob",
+			buildTree("GET *.a.com/", "PUT x.a.com/foo", "DELETE /foo"), "x.a.com", "/foo",
+			"DELETE,GET,HEAD,PUT",
+		},
 		{
 			// This case shouldn't come up because we only call matchingMethods
 			// when there was no match, but we include it for completeness.
@@ -269,6 +307,41 @@ func TestMatchingMethods(t *testing.T) {
 	}
 }
 
+func BenchmarkNextSegment(b *testing.B) {
+	path := "/item/jba/17/line2/"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < len(path); {
+			_, j = nextSegment(path, j)
+		}
+	}
+}
+
+func BenchmarkMatch(b *testing.B) {
+	tree := buildTree(
+		"/item/",
+		"POST /item/{user}",
+		"GET /item/{user}",
+		"/item/{user}/{id}",
+		"/item/{user}/new",
+		"/item/{$}",
+		"/path/{p...}")
+	for _, path := range []string{
+		"/item/jba/new",      // literal after wildcard
+		"/item/jba/17",       // two wildcards
+		"/item/jba/17/line2", // falls back to trailing slash
+		"/path/to/file",      // multi
+		"/nothing/here",      // no match
+	} {
+		b.Run(path, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tree.match("GET", "", path)
+			}
+		})
+	}
+}
+
 func (n *node) print(w io.Writer, level int) {
 	indent := strings.Repeat("    ", level)
 	if n.pattern != nil {
@@ -284,11 +357,17 @@ func (n *node) print(w io.Writer, level int) {
 		keys = append(keys, k)
 		return true
 	})
+	if n.multiChild != nil {
+		keys = append(keys, "*")
+	}
 	sort.Strings(keys)
 
 	for _, k := range keys {
 		fmt.Fprintf(w, "%s%q:\n", indent, k)
-		n, _ := n.children.find(k)
-		n.print(w, level+1)
+		c, _ := n.children.find(k)
+		if k == "*" && n.multiChild != nil {
+			c = n.multiChild
+		}
+		c.print(w, level+1)
 	}
 }